@@ -0,0 +1,48 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+
+	"titan-container-platform/core/dao"
+	"titan-container-platform/core/token"
+	"titan-container-platform/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getFaucetBalancesHandler(c *gin.Context) {
+	list, err := token.FaucetBalances()
+	if err != nil {
+		log.Errorf("FaucetBalances: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	total := new(big.Int)
+	for _, b := range list {
+		if v, ok := new(big.Int).SetString(b.Token, 10); ok {
+			total.Add(total, v)
+		}
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"list":  list,
+		"total": total.String(),
+	}))
+}
+
+func getSettlementTotalsHandler(c *gin.Context) {
+	amount, count, orders, err := dao.SettlementTotals()
+	if err != nil {
+		log.Errorf("SettlementTotals: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"amount":      amount,
+		"settlements": count,
+		"orders":      orders,
+	}))
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"image/color"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"titan-container-platform/config"
+	"titan-container-platform/errors"
+
+	config2 "github.com/TestsLing/aj-captcha-go/config"
+	constant "github.com/TestsLing/aj-captcha-go/const"
+	"github.com/TestsLing/aj-captcha-go/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCaptchaCacheSize = 10000
+	defaultCaptchaOffset    = 10
+)
+
+// factory serves the block puzzle and click word captchas, nil when the faucet is disabled.
+var factory *service.CaptchaServiceFactory
+
+// captchaParams is the answer to a captcha, sent to the check endpoint and with the faucet claims.
+type captchaParams struct {
+	CaptchaType string `json:"captchaType"`
+	Token       string `json:"token"`
+	PointJSON   string `json:"pointJson"`
+}
+
+// initCaptcha sets up the captcha services, keeping the captchas in memory unless a redis cache is configured.
+func initCaptcha(cfg *config.CaptchaConfig) {
+	resourcePath := cfg.ResourcePath
+	if resourcePath == "" {
+		resourcePath = constant.DefaultResourceRoot
+	}
+
+	// the services walk the image directories at creation and panic when they are missing
+	if _, err := os.Stat(filepath.Join(resourcePath, constant.DefaultBackgroundImageDirectory)); err != nil {
+		log.Fatalf("captcha resources not found under %s, copy the resources directory of "+
+			"github.com/TestsLing/aj-captcha-go there or set Captcha.ResourcePath: %v", resourcePath, err)
+	}
+
+	offset := cfg.Offset
+	if offset <= 0 {
+		offset = defaultCaptchaOffset
+	}
+
+	// 点击文字配置
+	clickWordConfig := &config2.ClickWordConfig{
+		FontSize: 25,
+		FontNum:  4,
+	}
+	// 水印配置
+	watermarkConfig := &config2.WatermarkConfig{
+		FontSize: 12,
+		Color:    color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		Text:     "",
+	}
+	// 滑动模块配置
+	blockPuzzleConfig := &config2.BlockPuzzleConfig{Offset: offset}
+
+	cacheType := cfg.CacheType
+	if cacheType == "" {
+		cacheType = constant.MemCacheKey
+	}
+	if cacheType != constant.MemCacheKey && cacheType != constant.RedisCacheKey {
+		log.Fatalf("captcha cache type %s not supported", cacheType)
+	}
+
+	captchaConfig := config2.BuildConfig(cacheType, resourcePath, watermarkConfig,
+		clickWordConfig, blockPuzzleConfig, cfg.CacheExpireSec)
+	factory = service.NewCaptchaServiceFactory(captchaConfig)
+
+	switch cacheType {
+	case constant.RedisCacheKey:
+		factory.RegisterCache(constant.RedisCacheKey, service.NewConfigRedisCacheService(cfg.RedisAddrs,
+			cfg.RedisUser, cfg.RedisPassword, cfg.RedisCluster, cfg.RedisDB))
+	default:
+		size := cfg.CacheSize
+		if size <= 0 {
+			size = defaultCaptchaCacheSize
+		}
+		factory.RegisterCache(constant.MemCacheKey, service.NewMemCacheService(size))
+	}
+
+	factory.RegisterService(constant.ClickWordCaptcha, service.NewClickWordCaptchaService(factory))
+	factory.RegisterService(constant.BlockPuzzleCaptcha, service.NewBlockPuzzleCaptchaService(factory))
+}
+
+// captchaService returns the service of the captcha type, nil when the type is unknown.
+func captchaService(captchaType string) service.CaptchaInterface {
+	switch captchaType {
+	case constant.ClickWordCaptcha, constant.BlockPuzzleCaptcha:
+		return factory.GetService(captchaType)
+	default:
+		return nil
+	}
+}
+
+// dropCaptcha removes a captcha from the cache, so each captcha is answered once.
+func dropCaptcha(token string) {
+	factory.GetCache().Delete(fmt.Sprintf(constant.CodeKeyPrefix, token))
+}
+
+// verifyCaptcha checks the answer to a captcha and uses the captcha up, whether the answer is right or not.
+func verifyCaptcha(p *captchaParams) bool {
+	ser := captchaService(p.CaptchaType)
+	if ser == nil || p.Token == "" {
+		return false
+	}
+
+	if err := ser.Verification(p.Token, p.PointJSON); err != nil {
+		dropCaptcha(p.Token)
+		return false
+	}
+
+	return true
+}
+
+func getCaptchaHandler(c *gin.Context) {
+	var params captchaParams
+	if err := c.BindJSON(&params); err != nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	ser := captchaService(params.CaptchaType)
+	if ser == nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	data, err := ser.Get()
+	if err != nil {
+		log.Errorf("getCaptchaHandler err:%v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(data))
+}
+
+// checkCaptchaHandler checks the answer to a captcha without using it up, so it can be sent with a
+// faucet claim. A wrong answer drops the captcha.
+func checkCaptchaHandler(c *gin.Context) {
+	var params captchaParams
+	if err := c.BindJSON(&params); err != nil || params.Token == "" {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	ser := captchaService(params.CaptchaType)
+	if ser == nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	if err := ser.Check(params.Token, params.PointJSON); err != nil {
+		dropCaptcha(params.Token)
+		c.JSON(http.StatusOK, respErrorCode(errors.CaptchaInvalid, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"msg": "success",
+	}))
+}
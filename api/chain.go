@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"titan-container-platform/chain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getChainInfoHandler serves the chain info that the frontend passes to Keplr.
+func getChainInfoHandler(info *chain.ChainInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, respJSON(info))
+	}
+}
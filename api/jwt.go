@@ -147,6 +147,23 @@ func jwtGinMiddleware(secretKey string) (*jwt.GinJWTMiddleware, error) {
 	})
 }
 
+// adminRequired rejects the requests of accounts that are not in the configured admins.
+func adminRequired(admins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := jwt.ExtractClaims(c)
+		account, _ := claims[identityKey].(string)
+
+		for _, admin := range admins {
+			if account == admin {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusOK, respErrorCode(errors.PermissionDenied, c))
+	}
+}
+
 func loginBySignature(c *gin.Context, userName, account, msg, publicKey string) (interface{}, error) {
 	nonce := getUserNonce(account)
 	if nonce == "" {
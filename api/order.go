@@ -1,15 +1,20 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"strconv"
 
+	"titan-container-platform/chain"
 	"titan-container-platform/core"
 	"titan-container-platform/core/dao"
 	"titan-container-platform/core/order"
 	"titan-container-platform/errors"
 
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
 	jwt "github.com/appleboy/gin-jwt/v2"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -22,17 +27,19 @@ func getPriceHandler(c *gin.Context) {
 	ram, _ := strconv.Atoi(c.Query("ram"))
 	duration, _ := strconv.Atoi(c.Query("duration"))
 	storage, _ := strconv.Atoi(c.Query("storage"))
+	periods, _ := strconv.Atoi(c.Query("periods"))
 
-	params := &core.OrderReq{CPUCores: cpu, RAMSize: ram, StorageSize: storage, Duration: duration}
+	params := &core.OrderReq{CPUCores: cpu, RAMSize: ram, StorageSize: storage, Duration: duration, Plan: c.Query("plan"), Periods: periods}
 	if checkOrderParams(params) > 0 {
 		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
 		return
 	}
 
-	cost := order.CalculateTotalCost(params)
+	quote := order.CalculateQuote(params)
 
 	c.JSON(http.StatusOK, respJSON(JSONObject{
-		"cost": cost,
+		"cost":      quote.Total,
+		"breakdown": quote,
 	}))
 }
 
@@ -78,21 +85,45 @@ func createOrderHandler(c *gin.Context) {
 		return
 	}
 
-	price := order.CalculateTotalCost(&params)
-
 	orderID := uuid.NewString()
-	order := &core.Order{
+	info := &core.Order{
 		Account:     account,
 		CPUCores:    params.CPUCores,
 		RAMSize:     params.RAMSize,
 		StorageSize: params.StorageSize,
-		Duration:    params.Duration,
+		Dimensions:  params.Dimensions,
 		Status:      core.OrderStatusCreated,
 		ID:          orderID,
-		Price:       price,
+		Mode:        params.Mode,
+	}
+
+	if params.Mode == core.OrderModeMetered {
+		balance, err := dao.GetAccountBalance(account)
+		if err != nil {
+			log.Errorf("GetAccountBalance: %v", err)
+			c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+			return
+		}
+
+		if balance <= 0 {
+			c.JSON(http.StatusOK, respErrorCode(errors.InsufficientBalance, c))
+			return
+		}
+
+		// metered orders are paid from the balance as they run
+		info.Status = core.OrderStatusPaid
+		info.SurgeFactor = order.CurrentSurgeFactor()
+	} else {
+		quote := order.CalculateQuote(&params)
+
+		info.Duration = quote.Duration
+		info.Plan = quote.Plan
+		info.Periods = params.Periods
+		info.Price = quote.Total
+		info.SurgeFactor = quote.SurgeFactor
 	}
 
-	err := dao.CreateOrder(c.Request.Context(), order)
+	err := dao.CreateOrder(c.Request.Context(), info)
 	if err != nil {
 		log.Errorf("CreateOrder: %v", err)
 		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
@@ -104,22 +135,272 @@ func createOrderHandler(c *gin.Context) {
 	}))
 }
 
-func checkOrderParams(order *core.OrderReq) int {
-	if order.CPUCores > 32 || order.CPUCores < 1 {
+func checkOrderParams(params *core.OrderReq) int {
+	if !order.CheckDimensions(order.RequestDimensions(params)) {
 		return errors.InvalidParams
 	}
 
-	if order.RAMSize > 64 || order.RAMSize < 1 {
+	switch params.Mode {
+	case core.OrderModePrepaid:
+	case core.OrderModeMetered:
+		// metered orders have no duration, the resources are the quota ceiling
+		return 0
+	default:
 		return errors.InvalidParams
 	}
 
-	if order.StorageSize > 4000 || order.StorageSize < 40 {
-		return errors.InvalidParams
+	if params.Plan != "" {
+		if !order.CheckPlan(params.Plan, params.Periods) {
+			return errors.InvalidParams
+		}
+
+		return 0
 	}
 
-	if order.Duration > 30*24 || order.Duration < 1 {
+	if params.Duration > 30*24 || params.Duration < 1 {
 		return errors.InvalidParams
 	}
 
 	return 0
 }
+
+func createDepositHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	var params struct {
+		Amount int `json:"amount"`
+	}
+	if err := c.BindJSON(&params); err != nil || params.Amount <= 0 {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	orderID := uuid.NewString()
+	info := &core.Order{
+		ID:      orderID,
+		Account: account,
+		Price:   params.Amount,
+		Status:  core.OrderStatusCreated,
+		Mode:    core.OrderModeDeposit,
+	}
+
+	err := dao.CreateOrder(c.Request.Context(), info)
+	if err != nil {
+		log.Errorf("CreateOrder: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"id": orderID,
+	}))
+}
+
+func getPrepaidBalanceHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	balance, err := dao.GetAccountBalance(account)
+	if err != nil {
+		log.Errorf("GetAccountBalance: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"balance": balance,
+	}))
+}
+
+func getOrderUsageHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	info, err := dao.GetOrder(c.Request.Context(), c.Query("id"))
+	if err != nil || info.Account != account {
+		c.JSON(http.StatusOK, respError(errors.ErrNotFound))
+		return
+	}
+
+	size, _ := strconv.Atoi(c.Query("size"))
+	page, _ := strconv.Atoi(c.Query("page"))
+
+	list, total, err := dao.LoadOrderUsageRecords(c.Request.Context(), info.ID, page, size)
+	if err != nil {
+		log.Errorf("LoadOrderUsageRecords: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"list":  list,
+		"total": total,
+	}))
+}
+
+func getChainOrdersHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	fromHeight, _ := strconv.ParseUint(c.Query("from_height"), 10, 64)
+	toHeight, _ := strconv.ParseUint(c.Query("to_height"), 10, 64)
+
+	filter := &core.ChainOrderFilter{Initiator: account, Status: c.Query("status"), FromHeight: fromHeight, ToHeight: toHeight}
+	list, err := order.ChainOrders(c.Request.Context(), filter, c.Query("start_after"), limit)
+	if err != nil {
+		log.Errorf("ChainOrders: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"list":       list,
+		"block_time": order.BlockTime().Seconds(),
+	}))
+}
+
+// loadPayableOrder loads an unpaid order of the account that is paid on the chain of this network.
+func loadPayableOrder(c *gin.Context, account, id string) (*core.Order, bool) {
+	info, err := dao.GetOrder(c.Request.Context(), id)
+	if err != nil || info.Account != account {
+		c.JSON(http.StatusOK, respError(errors.ErrNotFound))
+		return nil, false
+	}
+
+	if info.Status != core.OrderStatusCreated || info.Mode == core.OrderModeMetered || info.Network != dao.Network() {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return nil, false
+	}
+
+	return info, true
+}
+
+func getOrderPaymentHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	info, ok := loadPayableOrder(c, account, c.Query("id"))
+	if !ok {
+		return
+	}
+
+	msg, err := order.PaymentMsg(info)
+	if err != nil {
+		log.Errorf("PaymentMsg: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	allowance, err := order.AllowanceMsg(info)
+	if err != nil {
+		log.Errorf("AllowanceMsg: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	resp := JSONObject{
+		"msg":  executeMsgJSON(msg),
+		"memo": info.ID,
+	}
+	// the renewals of a commitment order need an allowance, signed in the same tx as the msg
+	if allowance != nil {
+		resp["allowance_msg"] = executeMsgJSON(allowance)
+	}
+
+	c.JSON(http.StatusOK, respJSON(resp))
+}
+
+// executeMsgJSON renders the contract msg for the wallet to sign.
+func executeMsgJSON(msg *chaintypes.MsgExecuteContract) JSONObject {
+	return JSONObject{
+		"type_url": sdk.MsgTypeURL(msg),
+		"value": JSONObject{
+			"sender":   msg.Sender,
+			"contract": msg.Contract,
+			"msg":      json.RawMessage(msg.Msg),
+			"funds":    []sdk.Coin{},
+		},
+	}
+}
+
+type submitPaymentReq struct {
+	ID      string `json:"id"`
+	TxBytes string `json:"tx_bytes"` // base64 encoded signed TxRaw
+}
+
+func submitOrderPaymentHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	var params submitPaymentReq
+	if err := c.BindJSON(&params); err != nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	txBytes, err := base64.StdEncoding.DecodeString(params.TxBytes)
+	if err != nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	info, ok := loadPayableOrder(c, account, params.ID)
+	if !ok {
+		return
+	}
+
+	hash, err := order.SubmitPayment(info, txBytes)
+	if err != nil {
+		log.Errorf("SubmitPayment %s: %v", info.ID, err)
+		if err == chain.ErrPaymentMismatch {
+			c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+			return
+		}
+
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"tx_hash": hash,
+	}))
+}
+
+type closeOrderReq struct {
+	ID string `json:"id"`
+}
+
+func closeOrderHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	account := claims[identityKey].(string)
+
+	var params closeOrderReq
+	if err := c.BindJSON(&params); err != nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	info, err := dao.GetOrder(c.Request.Context(), params.ID)
+	if err != nil || info.Account != account || info.Network != dao.Network() {
+		c.JSON(http.StatusOK, respError(errors.ErrNotFound))
+		return
+	}
+
+	err = order.CloseMeteredOrder(info.ID)
+	if err != nil {
+		log.Errorf("CloseMeteredOrder %s: %v", info.ID, err)
+		if err == order.ErrOrderNotClosable {
+			c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+			return
+		}
+
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"status": core.OrderStatusClosed,
+	}))
+}
@@ -5,39 +5,15 @@ import (
 	"io"
 	"strings"
 
+	"titan-container-platform/chain"
 	"titan-container-platform/config"
 
-	"github.com/TestsLing/aj-captcha-go/service"
 	"github.com/gin-gonic/gin"
 	logging "github.com/ipfs/go-log/v2"
 )
 
 var log = logging.Logger("api")
 
-// 行为校验初始化
-var (
-	factory *service.CaptchaServiceFactory
-)
-
-// func InitCaptcha() {
-// 	// 水印配置
-// 	clickWordConfig := &config2.ClickWordConfig{
-// 		FontSize: 25,
-// 		FontNum:  4,
-// 	}
-// 	// 点击文字配置
-// 	watermarkConfig := &config2.WatermarkConfig{
-// 		FontSize: 12,
-// 		Color:    color.RGBA{R: 255, G: 255, B: 255, A: 255},
-// 		Text:     "",
-// 	}
-// 	// 滑动模块配置
-// 	blockPuzzleConfig := &config2.BlockPuzzleConfig{Offset: 200}
-// 	configcap := config2.BuildConfig(constant.RedisCacheKey, config.Cfg.ResourcePath, watermarkConfig,
-// 		clickWordConfig, blockPuzzleConfig, 2*60)
-// 	factory = service.NewCaptchaServiceFactory(configcap)
-// }
-
 // ServerAPI initializes the server API with the provided configuration.
 func ServerAPI(cfg *config.Config) {
 	gin.SetMode(cfg.Mode)
@@ -56,6 +32,16 @@ func ServerAPI(cfg *config.Config) {
 		log.Fatalf("authMiddleware.MiddlewareInit: %v", err)
 	}
 
+	apiV1.GET("/chain/info", getChainInfoHandler(chain.NewChainInfo(&cfg.ChainAPI)))
+
+	// the captchas guard the faucet, which only runs on test networks
+	if cfg.ChainAPI.Testnet {
+		initCaptcha(&cfg.Captcha)
+		captcha := apiV1.Group("/captcha")
+		captcha.POST("/get", getCaptchaHandler)
+		captcha.POST("/check", checkCaptchaHandler)
+	}
+
 	user := apiV1.Group("/user")
 	user.GET("/login_before", getNonceStringHandler)
 	user.POST("/login", authMiddleware.LoginHandler)
@@ -65,13 +51,27 @@ func ServerAPI(cfg *config.Config) {
 	user.Use(authMiddleware.MiddlewareFunc())
 	user.GET("/info", getUserInfoHandler)
 	user.POST("/faucet", getTokenHandler)
+	user.GET("/faucet/status", getFaucetStatusHandler)
 	user.GET("/balance", getBalanceHandler)
+	user.GET("/history", getWalletHistoryHandler)
 
 	order := apiV1.Group("/order")
 	order.Use(authMiddleware.MiddlewareFunc())
 	order.GET("/price", getPriceHandler)
 	order.POST("/create", createOrderHandler)
 	order.GET("/history", getOrderHistoryHandler)
+	order.POST("/deposit", createDepositHandler)
+	order.GET("/balance", getPrepaidBalanceHandler)
+	order.GET("/usage", getOrderUsageHandler)
+	order.GET("/payment", getOrderPaymentHandler)
+	order.POST("/payment", submitOrderPaymentHandler)
+	order.POST("/close", closeOrderHandler)
+	order.GET("/chain", getChainOrdersHandler)
+
+	admin := apiV1.Group("/admin")
+	admin.Use(authMiddleware.MiddlewareFunc(), adminRequired(cfg.Admins))
+	admin.GET("/faucet/balances", getFaucetBalancesHandler)
+	admin.GET("/settlements", getSettlementTotalsHandler)
 
 	if err := r.Run(cfg.Listen); err != nil {
 		log.Fatalf("starting server: %v\n", err)
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -138,6 +139,18 @@ func getTokenHandler(c *gin.Context) {
 	claims := jwt.ExtractClaims(c)
 	id := claims[identityKey].(string)
 
+	var params captchaParams
+	if err := c.BindJSON(&params); err != nil {
+		c.JSON(http.StatusOK, respErrorCode(errors.InvalidParams, c))
+		return
+	}
+
+	// without the faucet there are no captchas, and ClaimTokens tells it is disabled
+	if factory != nil && !verifyCaptcha(&params) {
+		c.JSON(http.StatusOK, respErrorCode(errors.CaptchaInvalid, c))
+		return
+	}
+
 	code, err := token.ClaimTokens(id)
 	if code > 0 {
 		log.Errorf("getTokenHandler err:%v", err)
@@ -150,6 +163,20 @@ func getTokenHandler(c *gin.Context) {
 	}))
 }
 
+func getFaucetStatusHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	id := claims[identityKey].(string)
+
+	status, err := token.FaucetStatusOf(id)
+	if err != nil {
+		log.Errorf("getFaucetStatusHandler err:%v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(status))
+}
+
 func getBalanceHandler(c *gin.Context) {
 	claims := jwt.ExtractClaims(c)
 	id := claims[identityKey].(string)
@@ -160,7 +187,34 @@ func getBalanceHandler(c *gin.Context) {
 		return
 	}
 
+	native, err := token.GetNativeBalance(id)
+	if err != nil {
+		c.JSON(http.StatusOK, respError(errors.ErrNotFound))
+		return
+	}
+
 	c.JSON(http.StatusOK, respJSON(JSONObject{
 		"balance": balance,
+		"native":  native,
+	}))
+}
+
+func getWalletHistoryHandler(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	id := claims[identityKey].(string)
+
+	size, _ := strconv.Atoi(c.Query("size"))
+	page, _ := strconv.Atoi(c.Query("page"))
+
+	list, total, err := token.LoadHistory(c.Request.Context(), id, page, size)
+	if err != nil {
+		log.Errorf("LoadHistory: %v", err)
+		c.JSON(http.StatusOK, respErrorCode(errors.InternalServer, c))
+		return
+	}
+
+	c.JSON(http.StatusOK, respJSON(JSONObject{
+		"list":  list,
+		"total": total,
 	}))
 }
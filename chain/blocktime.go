@@ -0,0 +1,123 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosclient"
+)
+
+const (
+	// defaultBlockTime is used until the block time is first measured.
+	defaultBlockTime = time.Hour / blocksPerHour
+	// blockTimeInterval is how often the block time is measured.
+	blockTimeInterval = 10 * time.Minute
+	// blockTimeWindow is how many recent blocks the block time is averaged over.
+	blockTimeWindow = 1000
+)
+
+// ErrNoBlockTime is returned when no block has been seen yet to estimate the time of a height.
+var ErrNoBlockTime = errors.New("block time is not measured yet")
+
+// blockClock estimates the wall-clock time of block heights from the average block
+// time and the last seen block.
+type blockClock struct {
+	lock      sync.Mutex
+	blockTime time.Duration
+	height    int64
+	time      time.Time
+}
+
+func newBlockClock() *blockClock {
+	return &blockClock{blockTime: defaultBlockTime}
+}
+
+func (b *blockClock) update(blockTime time.Duration, height int64, at time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if blockTime > 0 {
+		b.blockTime = blockTime
+	}
+	b.height = height
+	b.time = at
+}
+
+// BlockTime returns the average block time.
+func (b *blockClock) BlockTime() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.blockTime
+}
+
+// HoursToBlocks returns the number of blocks that last the hours, rounded up.
+func (b *blockClock) HoursToBlocks(hours int) uint64 {
+	blockTime := b.BlockTime()
+
+	return uint64(math.Ceil(float64(time.Duration(hours)*time.Hour) / float64(blockTime)))
+}
+
+// HeightTime returns the estimated time of the block at the height, which may be in the future.
+func (b *blockClock) HeightTime(height int64) (time.Time, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.height == 0 {
+		return time.Time{}, ErrNoBlockTime
+	}
+
+	return b.time.Add(time.Duration(height-b.height) * b.blockTime), nil
+}
+
+// watchBlockTime measures the block time until the process exits.
+func (c *cosmosClient) watchBlockTime() {
+	ticker := time.NewTicker(blockTimeInterval)
+	defer ticker.Stop()
+
+	for {
+		err := c.measureBlockTime()
+		if err != nil {
+			log.Warnf("measure block time err:%s", err.Error())
+		}
+
+		<-ticker.C
+	}
+}
+
+// measureBlockTime averages the block time over the recent blocks from the times in their headers.
+func (c *cosmosClient) measureBlockTime() error {
+	return c.withRPC(func(tc *cosmosclient.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+		defer cancel()
+
+		latest, err := tc.RPC.Header(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		height := latest.Header.Height - blockTimeWindow
+		if height < 1 {
+			height = 1
+		}
+
+		if height >= latest.Header.Height {
+			c.blockClock.update(0, latest.Header.Height, latest.Header.Time)
+			return nil
+		}
+
+		past, err := tc.RPC.Header(ctx, &height)
+		if err != nil {
+			return err
+		}
+
+		blockTime := latest.Header.Time.Sub(past.Header.Time) / time.Duration(latest.Header.Height-height)
+		c.blockClock.update(blockTime, latest.Header.Height, latest.Header.Time)
+
+		log.Debugf("block time %s at height %d", blockTime, latest.Header.Height)
+		return nil
+	})
+}
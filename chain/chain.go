@@ -2,306 +2,216 @@ package chain
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"sync"
 
 	"titan-container-platform/config"
+	"titan-container-platform/core"
 
 	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
-	cosmostypes "github.com/cosmos/cosmos-sdk/types"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	"github.com/ignite/cli/v28/ignite/pkg/cosmosaccount"
-	"github.com/ignite/cli/v28/ignite/pkg/cosmosclient"
 	logging "github.com/ipfs/go-log/v2"
 )
 
 var log = logging.Logger("chain")
 
-var (
-	txClient *cosmosclient.Client
-	qClient  chaintypes.QueryClient
-)
-
-// var txClient *cosmosclient.qurey
-
-var (
-	prefix        = ""
-	rpc           = ""
-	tokenContract = ""
-	serviceName   = ""
-	keyringDir    = ""
-	faucetGas     = ""
-	// faucetToken   = ""
-	orderContract = ""
-)
-
-// Init initializes the keyring directory from the environment variable.
-func Init(cfg *config.ChainAPIConfig) {
-	prefix = cfg.AddressPrefix
-	rpc = cfg.RPC
-	tokenContract = cfg.TokenContractAddress
-	serviceName = cfg.ServiceName
-	keyringDir = cfg.KeyringDir
-	faucetGas = cfg.FaucetGas
-	// faucetToken = cfg.FaucetToken
-	orderContract = cfg.OrderContractAddress
-
-	tc, err := cosmosclient.New(context.Background(),
-		cosmosclient.WithAddressPrefix(prefix),
-		cosmosclient.WithNodeAddress(rpc),
-		cosmosclient.WithGas("600000"),
-		cosmosclient.WithGasPrices("0.0025uttnt"),
-		cosmosclient.WithKeyringServiceName(serviceName),
-		cosmosclient.WithKeyringDir(keyringDir),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	qc := chaintypes.NewQueryClient(tc.Context())
-
-	qClient = qc
-	txClient = &tc
-
-	GetOrders([]string{"order-1"})
+// cosmosClient is the Client that talks to the Titan RPC and gRPC nodes. Calls go
+// to the healthiest node and fail over to the others on errors.
+type cosmosClient struct {
+	rpcNodes  []*node
+	grpcNodes []*node
+	accounts  cosmosaccount.Registry
+	store     Store
+
+	prefix        string
+	tokenContract string
+	serviceName   string
+	keyringDir    string
+	faucetGas     string
+	orderContract string
+	depositAddr   string
+
+	gasPrices     string
+	gasAdjustment float64
+	maxGas        uint64
+
+	seqLock    sync.Mutex
+	sequencers map[string]*sequencer
+
+	faucets *faucetPool
+	claims  chan *claimRequest
+
+	*blockClock
 }
 
-// func testSend() {
-// balance, err := balance("titan17ljevhtqu4vx6y7k743jyca0w8gyfu2466e8x3")
-// log.Infof("balance coin:%s", balance)
-// coin := order.CalculateTotalCost(&core.OrderReq{CPUCores: 4, RAMSize: 4, StorageSize: 50, Duration: 12})
-// log.Infof("CalculateTotalCost coin:%d", coin)
-// err = sendOrder("order_123456", 4, 4, 50, 12, fmt.Sprintf("%d", coin))
-// log.Infof("sendOrder err:%v", err)
-// 	// outputs := []banktypes.Output{
-// 	// 	{
-// 	// 		Address: "titan17ljevhtqu4vx6y7k743jyca0w8gyfu2466e8x3",
-// 	// 		Coins:   cosmostypes.NewCoins(cosmostypes.Coin{Denom: "", Amount: math.NewInt(1000000)}),
-// 	// 	},
-// 	// }
-// 	// err := SendMsgs(outputs)
-// 	// if err != nil {
-// 	// 	log.Errorf("testSend err:%s", err.Error())
-// 	// }
-// 	toAddress := "titan17ljevhtqu4vx6y7k743jyca0w8gyfu2466e8x3"
-
-// 	err := faucetSend(toAddress)
-// 	if err != nil {
-// 		log.Errorf("SendMsg err:%s", err.Error())
-// 	}
-// }
-
-func getAccount() *cosmosaccount.Account {
-	acc, err := txClient.Account(serviceName)
-	if err != nil {
-		return nil
-	}
-
-	return &acc
-}
-
-// ClaimTokens transfers tokens to the specified address.
-func ClaimTokens(toAddress string, faucetToken string) error {
-	a := getAccount()
-	if a == nil {
-		return errors.New("no account found")
+// NewClient creates the client of the chain nodes of the configuration. The
+// nodes that are down are connected in the background, so the client starts in
+// a degraded mode where calls fail with ErrNoNode until a node is up. The broadcast
+// txs are recorded in the store.
+func NewClient(cfg *config.ChainAPIConfig, store Store) (Client, error) {
+	gasPrices := cfg.GasPrices
+	if gasPrices == "" {
+		gasPrices = defaultGasPrices
 	}
 
-	faucetAddr, err := a.Address(prefix)
-	if err != nil {
-		return err
+	gasAdjustment := cfg.GasAdjustment
+	if gasAdjustment <= 0 {
+		gasAdjustment = defaultGasAdjustment
 	}
 
-	// 合约代币
-	tokenBody := map[string]interface{}{
-		"transfer": map[string]interface{}{
-			"recipient": toAddress,
-			"amount":    faucetToken,
-		},
+	maxGas := cfg.MaxGas
+	if maxGas == 0 {
+		maxGas = defaultMaxGas
 	}
 
-	tokenJSONBody, err := json.Marshal(tokenBody)
+	accounts, err := cosmosaccount.New(
+		cosmosaccount.WithKeyringServiceName(cfg.ServiceName),
+		cosmosaccount.WithKeyringBackend(cosmosaccount.KeyringTest),
+		cosmosaccount.WithHome(cfg.KeyringDir),
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tokenReq := &chaintypes.MsgExecuteContract{Sender: faucetAddr, Contract: tokenContract, Msg: tokenJSONBody}
+	c := &cosmosClient{
+		accounts:      accounts,
+		store:         store,
+		prefix:        cfg.AddressPrefix,
+		tokenContract: cfg.TokenContractAddress,
+		serviceName:   cfg.ServiceName,
+		keyringDir:    cfg.KeyringDir,
+		faucetGas:     cfg.FaucetGas,
+		orderContract: cfg.OrderContractAddress,
+		depositAddr:   cfg.DepositAddress,
+		gasPrices:     gasPrices,
+		gasAdjustment: gasAdjustment,
+		maxGas:        maxGas,
+		sequencers:    make(map[string]*sequencer),
+		claims:        make(chan *claimRequest, maxClaimBatch),
+		blockClock:    newBlockClock(),
+	}
 
-	// 主币, 作为gas
-	gasCoins, err := cosmostypes.ParseCoinsNormalized(faucetGas)
-	if err != nil {
-		return err
+	rpcs := cfg.RPCs
+	if len(rpcs) == 0 {
+		rpcs = []string{cfg.RPC}
+	} else if cfg.RPC != "" {
+		log.Warnf("chain RPC %s is ignored, the RPCs are used", cfg.RPC)
 	}
-	outputs := []banktypes.Output{{
-		Address: toAddress,
-		Coins:   gasCoins,
-	}}
-
-	inputCoins := cosmostypes.NewCoins()
-	for _, o := range outputs {
-		inputCoins = inputCoins.Add(o.Coins...)
+	for _, url := range rpcs {
+		c.rpcNodes = append(c.rpcNodes, &node{url: url})
 	}
 
-	gasReq := &banktypes.MsgMultiSend{
-		Inputs: []banktypes.Input{{
-			Address: faucetAddr,
-			Coins:   inputCoins,
-		}},
-		Outputs: outputs,
+	for _, url := range cfg.GRPCs {
+		n, err := newGRPCNode(url)
+		if err != nil {
+			return nil, err
+		}
+		c.grpcNodes = append(c.grpcNodes, n)
 	}
 
-	log.Infof("Sending %s from faucet address [%s] to recipient [%s]", toAddress, faucetAddr, faucetToken)
-
-	// Send message and get response
-	_, err = txClient.BroadcastTx(context.Background(), *a, tokenReq, gasReq)
-	if err != nil {
-		return err
+	// the faucet has no wallets outside test networks, so every claim fails with ErrFaucetDry
+	c.faucets = &faucetPool{}
+	if cfg.Testnet {
+		c.faucets, err = c.newFaucetPool(cfg.FaucetKeys, cfg.FaucetMinGas, cfg.FaucetMinToken, cfg.FaucetAlertFloor, cfg.AlertURL)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
-}
-
-// GetBalance retrieves the balance for the specified address.
-func GetBalance(toAddress string) (string, error) {
-	a := getAccount()
-	if a == nil {
-		return "", errors.New("no account found")
+	c.checkNodes()
+	if len(ranked(c.rpcNodes)) == 0 {
+		log.Warn("no chain node is reachable, starting degraded")
 	}
 
-	tokenBody := map[string]interface{}{
-		"balance": map[string]interface{}{
-			"address": toAddress,
-		},
+	go c.watchNodes()
+	go c.watchBlockTime()
+	if cfg.Testnet {
+		go c.watchFaucets()
 	}
-
-	tokenJSONBody, err := json.Marshal(tokenBody)
-	if err != nil {
-		return "", err
+	for _, w := range c.faucets.wallets {
+		go c.runFaucet(w)
 	}
 
-	tokenReq := &chaintypes.QuerySmartContractStateRequest{Address: tokenContract, QueryData: tokenJSONBody}
+	return c, nil
+}
 
-	// Send message and get response
-	res, err := qClient.SmartContractState(context.Background(), tokenReq)
+func (c *cosmosClient) getAccount() *cosmosaccount.Account {
+	acc, err := c.accounts.GetByName(c.serviceName)
 	if err != nil {
-		log.Errorf("balance SmartContractState err:%s", err.Error())
-		return "", err
+		return nil
 	}
 
+	return &acc
+}
+
+// GetBalance retrieves the balance for the specified address.
+func (c *cosmosClient) GetBalance(toAddress string) (string, error) {
 	var resp balanceResponse
-	err = json.Unmarshal(res.Data, &resp)
+	err := c.queryContract(c.tokenContract, &TokenQueryMsg{Balance: &BalanceQuery{Address: toAddress}}, &resp)
 	if err != nil {
-		log.Fatalf("balance Error Unmarshal JSON: %v", err)
 		return "", err
 	}
 
 	return resp.Balance, nil
 }
 
-type balanceResponse struct {
-	Balance string `json:"balance"`
-}
-
-func sendOrder(id string, cpu, memory, disk, duration int, coin string) error {
-	a := getAccount()
-	if a == nil {
-		return errors.New("no account found")
-	}
-
-	faucetAddr, err := a.Address(prefix)
-	if err != nil {
-		return err
-	}
-
-	orderBody := map[string]interface{}{
-		"CreateOrder": map[string]interface{}{
-			"order_id": id,
-			"cpu":      cpu,
-			"memory":   memory,
-			"disk":     disk,
-			"duration": duration * 600,
-		},
-	}
-
-	orderJSONBody, err := json.Marshal(orderBody)
-	if err != nil {
-		return err
-	}
-
-	msg := map[string]interface{}{
-		"send": map[string]interface{}{
-			"amount":   coin,
-			"contract": orderContract,
-			"msg":      orderJSONBody,
-		},
-	}
-
-	orderMsg, err := json.Marshal(msg)
-	if err != nil {
+// GetNativeBalance retrieves the uttnt bank balance for the specified address.
+func (c *cosmosClient) GetNativeBalance(address string) (string, error) {
+	var res *banktypes.QueryBalanceResponse
+	err := c.withQuery(func(n *node) (err error) {
+		res, err = n.bank.Balance(context.Background(), &banktypes.QueryBalanceRequest{Address: address, Denom: gasDenom})
 		return err
-	}
-
-	orderReq := &chaintypes.MsgExecuteContract{Sender: faucetAddr, Contract: tokenContract, Msg: orderMsg}
-
-	log.Infof("sendOrder %s from faucet address", faucetAddr)
-
-	res, err := txClient.BroadcastTx(context.Background(), *a, orderReq)
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
-	log.Infoln(res)
 
-	return nil
+	return res.Balance.Amount.String(), nil
 }
 
-// TokenOrder represents an order for a token with a unique ID and duration.
-type TokenOrder struct {
-	ID          string `json:"id,omitempty"`
-	Duration    uint64 `json:"duration,omitempty"`
-	Initiator   string `json:"initiator,omitempty"`
-	LockedFunds uint64 `json:"locked_funds,omitempty"`
-	Resource    struct {
-		CPU    uint32 `json:"cpu,omitempty"`
-		Memory uint32 `json:"memory,omitempty"`
-		Disk   uint32 `json:"disk,omitempty"`
-	} `json:"resource"`
-	StartHeight uint64 `json:"start_height,omitempty"`
-	Status      string `json:"status,omitempty"`
+// OrderPaymentMsg builds the CW20 send from the sender to the order contract that creates and pays for the order.
+func (c *cosmosClient) OrderPaymentMsg(sender, id string, cpu, memory, disk int, blocks uint64, coin string) (*chaintypes.MsgExecuteContract, error) {
+	return orderPaymentMsg(c.tokenContract, c.orderContract, sender, id, cpu, memory, disk, blocks, coin)
 }
 
-// GetOrders retrieves orders based on the provided order IDs.
-func GetOrders(ids []string) ([]*TokenOrder, error) {
-	a := getAccount()
+// RenewalAllowanceMsg builds the CW20 increase_allowance with which the owner lets the
+// service account send the amount of its tokens to renew its orders.
+func (c *cosmosClient) RenewalAllowanceMsg(owner, amount string) (*chaintypes.MsgExecuteContract, error) {
+	a := c.getAccount()
 	if a == nil {
-		return nil, errors.New("no account found")
+		return nil, ErrNoAccount
 	}
 
-	tokenBody := map[string]interface{}{
-		"orders": map[string]interface{}{
-			"order_ids": ids,
-		},
-	}
-
-	tokenJSONBody, err := json.Marshal(tokenBody)
+	serviceAddr, err := a.Address(c.prefix)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenReq := &chaintypes.QuerySmartContractStateRequest{Address: orderContract, QueryData: tokenJSONBody}
+	return allowanceMsg(c.tokenContract, owner, serviceAddr, amount)
+}
+
+// BroadcastOrderPayment broadcasts a tx signed by the user and returns its hash.
+// The tx must carry the payment msgs built by OrderPaymentMsg and RenewalAllowanceMsg.
+func (c *cosmosClient) BroadcastOrderPayment(orderID string, txBytes []byte, payment ...*chaintypes.MsgExecuteContract) (string, error) {
+	n, err := c.rpcNode()
+	if err != nil {
+		return "", err
+	}
 
-	// Send message and get response
-	res, err := qClient.SmartContractState(context.Background(), tokenReq)
+	tx, err := n.client().Context().TxConfig.TxDecoder()(txBytes)
 	if err != nil {
-		log.Errorf("balance SmartContractState err:%s", err.Error())
-		return nil, err
+		return "", err
+	}
+
+	if !carriesPayment(tx.GetMsgs(), payment) {
+		return "", ErrPaymentMismatch
 	}
 
-	var list []*TokenOrder
-	err = json.Unmarshal(res.Data, &list)
+	records := []*core.ChainTx{{Purpose: core.TxPurposeOrderPayment, Account: payment[0].Sender, OrderID: orderID}}
+	res, err := c.sendTx(txBytes, records)
 	if err != nil {
-		log.Fatalf("balance Error Unmarshal JSON: %v", err)
-		return nil, err
+		return "", err
 	}
 
-	return list, nil
+	return c.finishBroadcast(records, res)
 }
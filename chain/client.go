@@ -0,0 +1,75 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+)
+
+// ErrNoAccount is returned when the service account is missing from the keyring.
+var ErrNoAccount = errors.New("no account found")
+
+// Client is the interface to the Titan chain used by the platform.
+type Client interface {
+	// GetBalance returns the CW20 token balance of the address.
+	GetBalance(address string) (string, error)
+	// GetNativeBalance returns the uttnt bank balance of the address.
+	GetNativeBalance(address string) (string, error)
+	// ClaimTokens transfers faucet gas coins and the amount of CW20 tokens to the address
+	// and returns the hash of the tx, which is recorded under the claim id. A claim that
+	// is not sent before the ctx is done fails with ErrFaucetDry.
+	ClaimTokens(ctx context.Context, claimID int64, toAddress, amount string) (string, error)
+	// GetOrders queries the order contract for the orders with the ids.
+	GetOrders(ids []string) ([]*TokenOrder, error)
+	// OrderPaymentMsg builds the unsigned msg with which the sender creates and pays for an order lasting the blocks.
+	OrderPaymentMsg(sender, id string, cpu, memory, disk int, blocks uint64, coin string) (*chaintypes.MsgExecuteContract, error)
+	// RenewalAllowanceMsg builds the unsigned msg with which the owner allows the service account to
+	// renew its orders for the amount of tokens.
+	RenewalAllowanceMsg(owner, amount string) (*chaintypes.MsgExecuteContract, error)
+	// BroadcastOrderPayment broadcasts a user-signed tx carrying the payment msgs of the order and returns its hash.
+	BroadcastOrderPayment(orderID string, txBytes []byte, payment ...*chaintypes.MsgExecuteContract) (string, error)
+	// LatestHeight returns the latest block height.
+	LatestHeight() (int64, error)
+	// BlockEvents returns the decoded contract events of the block at the height.
+	BlockEvents(height int64) ([]*Event, error)
+	// RenewOrder extends the order on the order contract by the blocks, paid from the owner's tokens
+	// within the allowance of the service account, and returns the hash of the tx.
+	RenewOrder(owner, id string, blocks uint64, coin string) (string, error)
+	// CancelOrder closes the order on the order contract and refunds its unsettled funds to the initiator.
+	CancelOrder(id string) (string, error)
+	// SettleOrder releases the funds of the elapsed blocks of the order to the provider.
+	SettleOrder(id string) (string, error)
+	// Withdraw transfers the amount of settled funds from the order contract to the provider.
+	Withdraw(amount string) (string, error)
+	// OrdersByInitiator queries a page of the orders of the initiator, after the order with the id startAfter.
+	OrdersByInitiator(initiator, startAfter string, limit int) ([]*TokenOrder, error)
+	// ListOrders queries a page of all orders, after the order with the id startAfter.
+	ListOrders(startAfter string, limit int) ([]*TokenOrder, error)
+	// FaucetBalances returns the balances of the faucet wallets.
+	FaucetBalances() ([]*FaucetBalance, error)
+	// GetTx returns the result of the tx with the hash, or ErrTxNotFound if it is not included yet.
+	GetTx(hash string) (*TxResult, error)
+	// BlockTime returns the average block time measured from the recent block headers.
+	BlockTime() time.Duration
+	// HoursToBlocks returns the number of blocks that last the hours at the average block time.
+	HoursToBlocks(hours int) uint64
+	// HeightTime returns the estimated time of the block at the height, which may be in the future.
+	HeightTime(height int64) (time.Time, error)
+}
+
+// TokenOrder represents an order for a token with a unique ID and duration.
+type TokenOrder struct {
+	ID          string `json:"id,omitempty"`
+	Duration    uint64 `json:"duration,omitempty"`
+	Initiator   string `json:"initiator,omitempty"`
+	LockedFunds uint64 `json:"locked_funds,omitempty"`
+	Resource    struct {
+		CPU    uint32 `json:"cpu,omitempty"`
+		Memory uint32 `json:"memory,omitempty"`
+		Disk   uint32 `json:"disk,omitempty"`
+	} `json:"resource"`
+	StartHeight uint64 `json:"start_height,omitempty"`
+	Status      string `json:"status,omitempty"`
+}
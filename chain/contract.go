@@ -0,0 +1,157 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+)
+
+// blocksPerHour is the nominal block rate, used until the block time is measured.
+const blocksPerHour = 600
+
+// OrderExecuteMsg is the execute msg of the order contract. Exactly one field is set.
+// CreateOrder and RenewOrder carry payment and are sent wrapped in a CW20 send.
+type OrderExecuteMsg struct {
+	CreateOrder *CreateOrderMsg `json:"CreateOrder,omitempty"`
+	RenewOrder  *RenewOrderMsg  `json:"RenewOrder,omitempty"`
+	CancelOrder *CancelOrderMsg `json:"CancelOrder,omitempty"`
+	SettleOrder *SettleOrderMsg `json:"SettleOrder,omitempty"`
+	Withdraw    *WithdrawMsg    `json:"Withdraw,omitempty"`
+}
+
+// CreateOrderMsg creates an order with the locked funds of the CW20 send.
+type CreateOrderMsg struct {
+	OrderID  string `json:"order_id"`
+	CPU      int    `json:"cpu"`
+	Memory   int    `json:"memory"`
+	Disk     int    `json:"disk"`
+	Duration uint64 `json:"duration"` // in blocks
+}
+
+// RenewOrderMsg extends an order with the locked funds of the CW20 send.
+type RenewOrderMsg struct {
+	OrderID  string `json:"order_id"`
+	Duration uint64 `json:"duration"` // in blocks
+}
+
+// CancelOrderMsg closes an order and refunds the unsettled funds to its initiator.
+type CancelOrderMsg struct {
+	OrderID string `json:"order_id"`
+}
+
+// SettleOrderMsg releases the funds of the elapsed blocks of an order to the provider.
+type SettleOrderMsg struct {
+	OrderID string `json:"order_id"`
+}
+
+// WithdrawMsg transfers the amount of settled funds to the provider.
+type WithdrawMsg struct {
+	Amount string `json:"amount"`
+}
+
+// OrderQueryMsg is the query msg of the order contract. Exactly one field is set.
+type OrderQueryMsg struct {
+	Orders            *OrdersQuery            `json:"orders,omitempty"`
+	OrdersByInitiator *OrdersByInitiatorQuery `json:"orders_by_initiator,omitempty"`
+	ListOrders        *ListOrdersQuery        `json:"list_orders,omitempty"`
+}
+
+// OrdersQuery returns the orders with the ids.
+type OrdersQuery struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+// OrdersByInitiatorQuery returns a page of the orders of the initiator.
+type OrdersByInitiatorQuery struct {
+	Initiator  string `json:"initiator"`
+	StartAfter string `json:"start_after,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// ListOrdersQuery returns a page of all orders.
+type ListOrdersQuery struct {
+	StartAfter string `json:"start_after,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// TokenExecuteMsg is the execute msg of the CW20 token contract. Exactly one field is set.
+type TokenExecuteMsg struct {
+	Transfer          *TransferMsg          `json:"transfer,omitempty"`
+	Send              *SendMsg              `json:"send,omitempty"`
+	SendFrom          *SendFromMsg          `json:"send_from,omitempty"`
+	IncreaseAllowance *IncreaseAllowanceMsg `json:"increase_allowance,omitempty"`
+}
+
+// TransferMsg transfers tokens from the sender to the recipient.
+type TransferMsg struct {
+	Recipient string `json:"recipient"`
+	Amount    string `json:"amount"`
+}
+
+// SendMsg sends tokens from the sender to the contract and executes the msg on it.
+type SendMsg struct {
+	Contract string `json:"contract"`
+	Amount   string `json:"amount"`
+	Msg      []byte `json:"msg"`
+}
+
+// SendFromMsg sends tokens from the owner to the contract with the allowance of the sender.
+type SendFromMsg struct {
+	Owner    string `json:"owner"`
+	Contract string `json:"contract"`
+	Amount   string `json:"amount"`
+	Msg      []byte `json:"msg"`
+}
+
+// IncreaseAllowanceMsg allows the spender to send the amount more of the sender's tokens.
+type IncreaseAllowanceMsg struct {
+	Spender string `json:"spender"`
+	Amount  string `json:"amount"`
+}
+
+// TokenQueryMsg is the query msg of the CW20 token contract.
+type TokenQueryMsg struct {
+	Balance *BalanceQuery `json:"balance,omitempty"`
+}
+
+// BalanceQuery returns the balance of the address.
+type BalanceQuery struct {
+	Address string `json:"address"`
+}
+
+type balanceResponse struct {
+	Balance string `json:"balance"`
+}
+
+// executeMsg builds the msg that executes the contract msg from the sender.
+func executeMsg(sender, contract string, msg interface{}) (*chaintypes.MsgExecuteContract, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chaintypes.MsgExecuteContract{Sender: sender, Contract: contract, Msg: body}, nil
+}
+
+// queryContract queries the smart state of the contract into resp.
+func (c *cosmosClient) queryContract(contract string, query, resp interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+
+	req := &chaintypes.QuerySmartContractStateRequest{Address: contract, QueryData: body}
+
+	var res *chaintypes.QuerySmartContractStateResponse
+	err = c.withQuery(func(n *node) error {
+		res, err = n.query.SmartContractState(context.Background(), req)
+		return err
+	})
+	if err != nil {
+		log.Errorf("SmartContractState %s err:%s", contract, err.Error())
+		return err
+	}
+
+	return json.Unmarshal(res.Data, resp)
+}
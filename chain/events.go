@@ -0,0 +1,209 @@
+package chain
+
+import (
+	"context"
+	"time"
+
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosclient"
+)
+
+// EventType is the kind of a decoded contract event.
+type EventType string
+
+const (
+	// EventOrderCreated is emitted by the order contract when an order is created.
+	EventOrderCreated EventType = "order_created"
+	// EventOrderRenewed is emitted by the order contract when an order is extended.
+	EventOrderRenewed EventType = "order_renewed"
+	// EventFundsLocked is a token send into the order contract for an order.
+	EventFundsLocked EventType = "funds_locked"
+	// EventOrderClosed is emitted by the order contract when an order is cancelled or finished.
+	EventOrderClosed EventType = "order_closed"
+	// EventOrderSettled is emitted by the order contract when the earned funds of an order are
+	// released to the provider, with the released amount.
+	EventOrderSettled EventType = "order_settled"
+	// EventTokenTransfer is a plain transfer of the token contract.
+	EventTokenTransfer EventType = "token_transfer"
+	// EventDepositTransfer is a plain transfer of the token contract to the deposit
+	// address, which pays the order in the memo of its tx.
+	EventDepositTransfer EventType = "deposit_transfer"
+)
+
+const (
+	wasmEventType           = "wasm"
+	contractAddressAttrKey  = "_contract_address"
+	actionAttrKey           = "action"
+	orderIDAttrKey          = "order_id"
+	initiatorAttrKey        = "initiator"
+	amountAttrKey           = "amount"
+	fromAttrKey             = "from"
+	toAttrKey               = "to"
+	tokenSendAction         = "send"
+	tokenTransferAction     = "transfer"
+	tokenTransferFromAction = "transfer_from"
+	tokenSendFromAction     = "send_from"
+)
+
+// orderActions maps the actions of the order contract to event types.
+var orderActions = map[string]EventType{
+	"create_order": EventOrderCreated,
+	"renew_order":  EventOrderRenewed,
+	"cancel_order": EventOrderClosed,
+	"close_order":  EventOrderClosed,
+	"finish_order": EventOrderClosed,
+	"settle_order": EventOrderSettled,
+}
+
+// Event is a decoded wasm event of the order or token contract.
+type Event struct {
+	Type       EventType
+	Height     int64
+	Time       time.Time
+	TxHash     string
+	Index      int    // position of the event in its tx
+	Memo       string // memo of the tx
+	Contract   string
+	Action     string
+	OrderID    string
+	From       string
+	To         string
+	Amount     string
+	Attributes map[string]string
+}
+
+// LatestHeight returns the latest block height.
+func (c *cosmosClient) LatestHeight() (int64, error) {
+	var height int64
+	err := c.withRPC(func(tc *cosmosclient.Client) (err error) {
+		height, err = tc.LatestBlockHeight(context.Background())
+		return err
+	})
+
+	return height, err
+}
+
+// BlockEvents returns the decoded contract events of the successful txs in the block.
+func (c *cosmosClient) BlockEvents(height int64) ([]*Event, error) {
+	var txs []cosmosclient.TX
+	err := c.withRPC(func(tc *cosmosclient.Client) (err error) {
+		txs, err = tc.GetBlockTXs(context.Background(), height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Event
+	for _, tx := range txs {
+		if tx.Raw.TxResult.Code != 0 {
+			continue
+		}
+
+		memo := txMemo(tx.Raw.Tx)
+		for _, e := range c.decodeTxEvents(wasmAttributes(tx.Raw.TxResult.Events)) {
+			e.Height = tx.Raw.Height
+			e.Time = tx.BlockTime
+			e.TxHash = tx.Raw.Hash.String()
+			e.Memo = memo
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+// wasmAttributes returns the attributes of each wasm event of a tx.
+func wasmAttributes(events []abcitypes.Event) []map[string]string {
+	var out []map[string]string
+	for _, e := range events {
+		if e.Type != wasmEventType {
+			continue
+		}
+
+		attrs := make(map[string]string, len(e.Attributes))
+		for _, a := range e.Attributes {
+			attrs[a.Key] = a.Value
+		}
+		out = append(out, attrs)
+	}
+
+	return out
+}
+
+// decodeTxEvents turns the wasm events of one tx into typed events. A token send
+// into the order contract is linked to the order of the order contract event in
+// the same tx.
+func (c *cosmosClient) decodeTxEvents(wasmEvents []map[string]string) []*Event {
+	var out []*Event
+	var locked []*Event
+	orderID := ""
+
+	for i, attrs := range wasmEvents {
+		e := &Event{
+			Index:      i,
+			Contract:   attrs[contractAddressAttrKey],
+			Action:     attrs[actionAttrKey],
+			OrderID:    attrs[orderIDAttrKey],
+			From:       attrs[fromAttrKey],
+			To:         attrs[toAttrKey],
+			Amount:     attrs[amountAttrKey],
+			Attributes: attrs,
+		}
+
+		switch e.Contract {
+		case c.orderContract:
+			t, ok := orderActions[e.Action]
+			if !ok {
+				continue
+			}
+
+			e.Type = t
+			if e.From == "" {
+				e.From = attrs[initiatorAttrKey]
+			}
+			if e.OrderID != "" {
+				orderID = e.OrderID
+			}
+		case c.tokenContract:
+			switch {
+			case (e.Action == tokenSendAction || e.Action == tokenSendFromAction) && e.To == c.orderContract:
+				e.Type = EventFundsLocked
+				locked = append(locked, e)
+			case e.Action == tokenTransferAction && c.depositAddr != "" && e.To == c.depositAddr:
+				e.Type = EventDepositTransfer
+			case e.Action == tokenTransferAction || e.Action == tokenTransferFromAction:
+				e.Type = EventTokenTransfer
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	for _, e := range locked {
+		e.OrderID = orderID
+	}
+
+	return out
+}
+
+// txMemo returns the memo of the raw tx. Only the body is decoded, so the msgs
+// do not have to be registered.
+func txMemo(raw []byte) string {
+	var txRaw txtypes.TxRaw
+	if err := txRaw.Unmarshal(raw); err != nil {
+		return ""
+	}
+
+	var body txtypes.TxBody
+	if err := body.Unmarshal(txRaw.BodyBytes); err != nil {
+		return ""
+	}
+
+	return body.Memo
+}
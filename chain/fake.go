@@ -0,0 +1,566 @@
+package chain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"titan-container-platform/core"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+)
+
+const (
+	fakeTokenContract = "titan1faketoken"
+	fakeOrderContract = "titan1fakeorder"
+	fakeProvider      = "titan1fakeprovider"
+	fakeDeposit       = "titan1fakedeposit"
+	fakeService       = "titan1fakeservice"
+)
+
+// FakeClient is an in-memory Client for running the platform without a Titan RPC node.
+// Balances, orders and events can be scripted with SetBalance, SetOrder, Deposit and AddBlock.
+type FakeClient struct {
+	store    Store
+	lock     sync.Mutex
+	balances map[string]*big.Int
+	// allowances are the tokens of each owner the service account may send
+	allowances map[string]*big.Int
+	orders     map[string]*TokenOrder
+	height     int64
+	events     map[int64][]*Event
+	txs        map[string]*TxResult
+	err        error
+
+	// the blocks are taken to be the default block time apart, ending at the last added one
+	*blockClock
+}
+
+var _ Client = (*FakeClient)(nil)
+
+// NewFakeClient creates an empty in-memory client that records its txs in the store.
+func NewFakeClient(store Store) *FakeClient {
+	f := &FakeClient{
+		store:      store,
+		balances:   make(map[string]*big.Int),
+		allowances: make(map[string]*big.Int),
+		orders:     make(map[string]*TokenOrder),
+		height:     1,
+		events:     make(map[int64][]*Event),
+		txs:        make(map[string]*TxResult),
+		blockClock: newBlockClock(),
+	}
+	f.blockClock.update(0, f.height, time.Now())
+
+	return f
+}
+
+// SetBalance sets the CW20 token balance of the address.
+func (f *FakeClient) SetBalance(address string, amount string) error {
+	v, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return fmt.Errorf("invalid amount %s", amount)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.balances[address] = v
+	return nil
+}
+
+// SetOrder adds or replaces an order on the fake order contract.
+func (f *FakeClient) SetOrder(order *TokenOrder) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.orders[order.ID] = order
+}
+
+// AddBlock adds a block holding the events and returns its height.
+func (f *FakeClient) AddBlock(events ...*Event) int64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.addBlock(events...)
+}
+
+func (f *FakeClient) addBlock(events ...*Event) int64 {
+	f.height++
+	now := time.Now()
+	for _, e := range events {
+		e.Height = f.height
+		e.Time = now
+	}
+	f.events[f.height] = events
+	f.blockClock.update(0, f.height, now)
+
+	return f.height
+}
+
+// Deposit transfers the amount from the address to the deposit address in a tx
+// with the memo, as a wallet paying an order by a plain transfer does.
+func (f *FakeClient) Deposit(from, memo, amount string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.add(from, "-"+amount); err != nil {
+		return "", err
+	}
+	if err := f.add(fakeDeposit, amount); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(from + memo + amount + time.Now().String()))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	f.addBlock(&Event{Type: EventDepositTransfer, TxHash: hash, Memo: memo, Contract: fakeTokenContract, Action: tokenTransferAction, From: from, To: fakeDeposit, Amount: amount})
+
+	return hash, nil
+}
+
+// LatestHeight returns the height of the last added block.
+func (f *FakeClient) LatestHeight() (int64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	return f.height, nil
+}
+
+// BlockEvents returns the events of the block at the height.
+func (f *FakeClient) BlockEvents(height int64) ([]*Event, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.events[height], nil
+}
+
+// SetError makes every following call fail with err, or succeed again when err is nil.
+func (f *FakeClient) SetError(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.err = err
+}
+
+// GetBalance returns the scripted balance of the address.
+func (f *FakeClient) GetBalance(address string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	if v, ok := f.balances[address]; ok {
+		return v.String(), nil
+	}
+
+	return "0", nil
+}
+
+// GetNativeBalance returns no uttnt, the fake charges no gas.
+func (f *FakeClient) GetNativeBalance(address string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	return "0", nil
+}
+
+// ClaimTokens adds the amount to the balance of the address.
+func (f *FakeClient) ClaimTokens(ctx context.Context, claimID int64, toAddress, amount string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	if err := f.spendable(toAddress, amount); err != nil {
+		return "", err
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeFaucet, Account: toAddress, ClaimID: claimID}, []byte(toAddress+amount))
+	if err != nil {
+		return "", err
+	}
+
+	f.add(toAddress, amount)
+	return hash, nil
+}
+
+// GetOrders returns the known orders with the ids.
+func (f *FakeClient) GetOrders(ids []string) ([]*TokenOrder, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	list := make([]*TokenOrder, 0, len(ids))
+	for _, id := range ids {
+		if o, ok := f.orders[id]; ok {
+			list = append(list, o)
+		}
+	}
+
+	return list, nil
+}
+
+// OrderPaymentMsg builds the same msg as the RPC client, for fake contract addresses.
+func (f *FakeClient) OrderPaymentMsg(sender, id string, cpu, memory, disk int, blocks uint64, coin string) (*chaintypes.MsgExecuteContract, error) {
+	return orderPaymentMsg(fakeTokenContract, fakeOrderContract, sender, id, cpu, memory, disk, blocks, coin)
+}
+
+// RenewalAllowanceMsg builds the same msg as the RPC client, for the fake service account.
+func (f *FakeClient) RenewalAllowanceMsg(owner, amount string) (*chaintypes.MsgExecuteContract, error) {
+	return allowanceMsg(fakeTokenContract, owner, fakeService, amount)
+}
+
+// BroadcastOrderPayment applies the payment msgs without looking at the tx: the
+// amount moves from the sender's balance into a new order, and the allowance of
+// the service account grows.
+func (f *FakeClient) BroadcastOrderPayment(orderID string, txBytes []byte, payment ...*chaintypes.MsgExecuteContract) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	var send *SendMsg
+	allowance := new(big.Int)
+	for _, p := range payment {
+		var msg TokenExecuteMsg
+		if err := json.Unmarshal(p.Msg, &msg); err != nil {
+			return "", err
+		}
+
+		if msg.Send != nil {
+			send = msg.Send
+		}
+		if msg.IncreaseAllowance != nil && msg.IncreaseAllowance.Spender == fakeService {
+			amount, ok := new(big.Int).SetString(msg.IncreaseAllowance.Amount, 10)
+			if !ok {
+				return "", fmt.Errorf("invalid amount %s", msg.IncreaseAllowance.Amount)
+			}
+			allowance.Add(allowance, amount)
+		}
+	}
+	if send == nil {
+		return "", ErrPaymentMismatch
+	}
+	sender := payment[0].Sender
+
+	var create OrderExecuteMsg
+	if err := json.Unmarshal(send.Msg, &create); err != nil {
+		return "", err
+	}
+	if create.CreateOrder == nil {
+		return "", ErrPaymentMismatch
+	}
+
+	if err := f.spendable(sender, "-"+send.Amount); err != nil {
+		return "", err
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeOrderPayment, Account: sender, OrderID: orderID}, txBytes)
+	if err != nil {
+		return "", err
+	}
+
+	f.add(sender, "-"+send.Amount)
+	if allowance.Sign() > 0 {
+		f.allowances[sender] = new(big.Int).Add(f.allowance(sender), allowance)
+	}
+
+	funds, _ := new(big.Int).SetString(send.Amount, 10)
+	o := &TokenOrder{
+		ID:          create.CreateOrder.OrderID,
+		Duration:    create.CreateOrder.Duration,
+		Initiator:   sender,
+		LockedFunds: funds.Uint64(),
+		StartHeight: uint64(f.height + 1),
+		Status:      "created",
+	}
+	o.Resource.CPU = uint32(create.CreateOrder.CPU)
+	o.Resource.Memory = uint32(create.CreateOrder.Memory)
+	o.Resource.Disk = uint32(create.CreateOrder.Disk)
+	f.orders[o.ID] = o
+
+	f.addBlock(
+		&Event{Type: EventFundsLocked, TxHash: hash, Contract: fakeTokenContract, Action: tokenSendAction, OrderID: o.ID, From: sender, To: fakeOrderContract, Amount: send.Amount},
+		&Event{Type: EventOrderCreated, TxHash: hash, Contract: fakeOrderContract, Action: "create_order", OrderID: o.ID, From: sender, Amount: send.Amount},
+	)
+
+	return hash, nil
+}
+
+// RenewOrder moves the amount from the owner's balance into the order, within the
+// allowance of the service account.
+func (f *FakeClient) RenewOrder(owner, id string, blocks uint64, coin string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	o, ok := f.orders[id]
+	if !ok {
+		return "", fmt.Errorf("order %s not found", id)
+	}
+
+	funds, ok := new(big.Int).SetString(coin, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid amount %s", coin)
+	}
+
+	allowance := new(big.Int).Sub(f.allowance(owner), funds)
+	if allowance.Sign() < 0 {
+		return "", fmt.Errorf("insufficient allowance of %s", owner)
+	}
+
+	if err := f.spendable(owner, "-"+coin); err != nil {
+		return "", err
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeOrderRenewal, Account: owner, OrderID: id}, []byte(id+coin))
+	if err != nil {
+		return "", err
+	}
+
+	f.add(owner, "-"+coin)
+	f.allowances[owner] = allowance
+	o.Duration += blocks
+	o.LockedFunds += funds.Uint64()
+
+	f.addBlock(
+		&Event{Type: EventFundsLocked, TxHash: hash, Contract: fakeTokenContract, Action: tokenSendFromAction, OrderID: id, From: owner, To: fakeOrderContract, Amount: coin},
+		&Event{Type: EventOrderRenewed, TxHash: hash, Contract: fakeOrderContract, Action: "renew_order", OrderID: id, From: owner, Amount: coin},
+	)
+
+	return hash, nil
+}
+
+// CancelOrder refunds the locked funds of the order to its initiator and closes it.
+func (f *FakeClient) CancelOrder(id string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	o, ok := f.orders[id]
+	if !ok {
+		return "", fmt.Errorf("order %s not found", id)
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeOrderCancel, OrderID: id}, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	refund := strconv.FormatUint(o.LockedFunds, 10)
+	f.add(o.Initiator, refund)
+	o.LockedFunds = 0
+	o.Status = "cancelled"
+
+	f.addBlock(&Event{Type: EventOrderClosed, TxHash: hash, Contract: fakeOrderContract, Action: "cancel_order", OrderID: id, To: o.Initiator, Amount: refund})
+
+	return hash, nil
+}
+
+// SettleOrder releases all locked funds of the order to the fake provider, the fake has no block times.
+// The tx carries the settle event with the released amount.
+func (f *FakeClient) SettleOrder(id string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	o, ok := f.orders[id]
+	if !ok {
+		return "", fmt.Errorf("order %s not found", id)
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeOrderSettle, OrderID: id}, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	released := strconv.FormatUint(o.LockedFunds, 10)
+	f.add(fakeProvider, released)
+	o.LockedFunds = 0
+
+	f.txs[hash].Events = []*Event{{Type: EventOrderSettled, Contract: fakeOrderContract, Action: "settle_order", OrderID: id, Amount: released}}
+
+	return hash, nil
+}
+
+// Withdraw takes the amount off the settled funds of the fake provider.
+func (f *FakeClient) Withdraw(amount string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return "", f.err
+	}
+
+	if err := f.spendable(fakeProvider, "-"+amount); err != nil {
+		return "", err
+	}
+
+	hash, err := f.includeTx(&core.ChainTx{Purpose: core.TxPurposeWithdraw}, []byte(amount))
+	if err != nil {
+		return "", err
+	}
+
+	f.add(fakeProvider, "-"+amount)
+	return hash, nil
+}
+
+// OrdersByInitiator returns a page of the orders of the initiator, by id.
+func (f *FakeClient) OrdersByInitiator(initiator, startAfter string, limit int) ([]*TokenOrder, error) {
+	return f.listOrders(func(o *TokenOrder) bool { return o.Initiator == initiator }, startAfter, limit)
+}
+
+// ListOrders returns a page of all orders, by id.
+func (f *FakeClient) ListOrders(startAfter string, limit int) ([]*TokenOrder, error) {
+	return f.listOrders(func(o *TokenOrder) bool { return true }, startAfter, limit)
+}
+
+func (f *FakeClient) listOrders(match func(o *TokenOrder) bool, startAfter string, limit int) ([]*TokenOrder, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	ids := make([]string, 0, len(f.orders))
+	for id, o := range f.orders {
+		if id > startAfter && match(o) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	list := make([]*TokenOrder, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, f.orders[id])
+	}
+
+	return list, nil
+}
+
+// FaucetBalances returns no wallets, the fake faucet never runs dry.
+func (f *FakeClient) FaucetBalances() ([]*FaucetBalance, error) {
+	return []*FaucetBalance{}, nil
+}
+
+// GetTx returns the result of a tx the fake has included.
+func (f *FakeClient) GetTx(hash string) (*TxResult, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	res, ok := f.txs[hash]
+	if !ok {
+		return nil, ErrTxNotFound
+	}
+
+	return res, nil
+}
+
+// includeTx records a tx as pending and makes it succeed in the next block. As with
+// the RPC client, a tx that cannot be recorded is not sent and changes nothing.
+func (f *FakeClient) includeTx(record *core.ChainTx, data []byte) (string, error) {
+	sum := sha256.Sum256(append(data, []byte(time.Now().String())...))
+	record.Hash = strings.ToUpper(hex.EncodeToString(sum[:]))
+	record.Status = core.TxStatusPending
+
+	err := f.store.CreateChainTxs([]*core.ChainTx{record})
+	if err != nil {
+		return "", fmt.Errorf("record %s tx %s: %w", record.Purpose, record.Hash, err)
+	}
+
+	f.txs[record.Hash] = &TxResult{Hash: record.Hash, Height: f.height + 1}
+
+	return record.Hash, nil
+}
+
+// allowance returns the tokens of the owner the service account may send.
+func (f *FakeClient) allowance(owner string) *big.Int {
+	if v, ok := f.allowances[owner]; ok {
+		return v
+	}
+
+	return new(big.Int)
+}
+
+// add adds the amount to the balance of the address.
+func (f *FakeClient) add(address, amount string) error {
+	balance, err := f.balanceAfter(address, amount)
+	if err != nil {
+		return err
+	}
+
+	f.balances[address] = balance
+	return nil
+}
+
+// spendable checks that adding the amount to the balance of the address leaves it valid.
+func (f *FakeClient) spendable(address, amount string) error {
+	_, err := f.balanceAfter(address, amount)
+	return err
+}
+
+func (f *FakeClient) balanceAfter(address, amount string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %s", amount)
+	}
+
+	balance, ok := f.balances[address]
+	if !ok {
+		balance = new(big.Int)
+	}
+
+	balance = new(big.Int).Add(balance, v)
+	if balance.Sign() < 0 {
+		return nil, fmt.Errorf("insufficient balance of %s", address)
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,329 @@
+package chain
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"titan-container-platform/core"
+)
+
+const testUser = "titan1fakeuser"
+
+// memStore keeps what the client records in memory.
+type memStore struct {
+	lock    sync.Mutex
+	txs     []*core.ChainTx
+	heights map[string]int64
+	orders  map[string]*core.ChainOrder
+}
+
+func newMemStore() *memStore {
+	return &memStore{heights: make(map[string]int64), orders: make(map[string]*core.ChainOrder)}
+}
+
+func (s *memStore) CreateChainTxs(list []*core.ChainTx) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, tx := range list {
+		copied := *tx
+		s.txs = append(s.txs, &copied)
+	}
+	return nil
+}
+
+func (s *memStore) UpdateChainTxResult(tx *core.ChainTx) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, saved := range s.txs {
+		if saved.Hash == tx.Hash {
+			saved.Status, saved.Code, saved.GasUsed, saved.Height, saved.Log = tx.Status, tx.Code, tx.GasUsed, tx.Height, tx.Log
+		}
+	}
+	return nil
+}
+
+func (s *memStore) LoadChainTxsByStatus(status core.TxStatus) ([]*core.ChainTx, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var list []*core.ChainTx
+	for _, tx := range s.txs {
+		if tx.Status == status {
+			copied := *tx
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (s *memStore) GetSyncHeight(name string) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.heights[name], nil
+}
+
+func (s *memStore) SetSyncHeight(name string, height int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.heights[name] = height
+	return nil
+}
+
+func (s *memStore) SaveChainOrders(list []*core.ChainOrder, syncedAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, o := range list {
+		copied := *o
+		copied.SyncedAt = syncedAt
+		s.orders[o.ID] = &copied
+	}
+	return nil
+}
+
+func (s *memStore) DeleteChainOrdersSyncedBefore(t time.Time) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var removed int64
+	for id, o := range s.orders {
+		if o.SyncedAt.Before(t) {
+			delete(s.orders, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func assertBalance(t *testing.T, f *FakeClient, address, want string) {
+	t.Helper()
+
+	got, err := f.GetBalance(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("balance of %s is %s, want %s", address, got, want)
+	}
+}
+
+// payOrder pays an order of the test user lasting 6000 blocks with 1000 tokens.
+func payOrder(t *testing.T, f *FakeClient) string {
+	t.Helper()
+
+	msg, err := f.OrderPaymentMsg(testUser, testOrderID, 2, 4, 50, 6000, "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := f.BroadcastOrderPayment(testOrderID, []byte("signed"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func TestFakeOrderPayment(t *testing.T) {
+	store := newMemStore()
+	f := NewFakeClient(store)
+	if err := f.SetBalance(testUser, "1500"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := payOrder(t, f)
+	assertBalance(t, f, testUser, "500")
+
+	orders, err := f.GetOrders([]string{testOrderID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].LockedFunds != 1000 || orders[0].Duration != 6000 || orders[0].Initiator != testUser {
+		t.Fatalf("unexpected orders %+v", orders)
+	}
+
+	if len(store.txs) != 1 || store.txs[0].Purpose != core.TxPurposeOrderPayment || store.txs[0].OrderID != testOrderID || store.txs[0].Hash != hash {
+		t.Errorf("unexpected recorded txs %+v", store.txs)
+	}
+
+	height, err := f.LatestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := f.BlockEvents(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != EventFundsLocked || events[1].Type != EventOrderCreated || events[1].OrderID != testOrderID {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	res, err := f.GetTx(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != 0 || res.Height != height {
+		t.Errorf("unexpected tx result %+v", res)
+	}
+}
+
+func TestFakeOrderPaymentOverBalance(t *testing.T) {
+	f := NewFakeClient(newMemStore())
+	if err := f.SetBalance(testUser, "999"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := f.OrderPaymentMsg(testUser, testOrderID, 2, 4, 50, 6000, "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.BroadcastOrderPayment(testOrderID, []byte("signed"), msg); err == nil {
+		t.Fatal("a payment over the balance went through")
+	}
+
+	assertBalance(t, f, testUser, "999")
+}
+
+func TestFakeRenewalAllowance(t *testing.T) {
+	f := NewFakeClient(newMemStore())
+	if err := f.SetBalance(testUser, "3000"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := f.OrderPaymentMsg(testUser, testOrderID, 2, 4, 50, 6000, "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowance, err := f.RenewalAllowanceMsg(testUser, "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.BroadcastOrderPayment(testOrderID, []byte("signed"), msg, allowance); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.RenewOrder(testUser, testOrderID, 6000, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, f, testUser, "1000")
+
+	// the allowance covered a single renewal
+	if _, err := f.RenewOrder(testUser, testOrderID, 6000, "1000"); err == nil {
+		t.Error("a renewal over the allowance went through")
+	}
+	assertBalance(t, f, testUser, "1000")
+}
+
+func TestFakeSettlement(t *testing.T) {
+	store := newMemStore()
+	f := NewFakeClient(store)
+	if err := f.SetBalance(testUser, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	payOrder(t, f)
+
+	if _, err := f.SettleOrder(testOrderID); err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, f, fakeProvider, "1000")
+
+	if _, err := f.Withdraw("400"); err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, f, fakeProvider, "600")
+
+	if _, err := f.Withdraw("601"); err == nil {
+		t.Error("a withdrawal over the settled funds went through")
+	}
+
+	purposes := make([]core.TxPurpose, 0, len(store.txs))
+	for _, tx := range store.txs {
+		purposes = append(purposes, tx.Purpose)
+	}
+	want := []core.TxPurpose{core.TxPurposeOrderPayment, core.TxPurposeOrderSettle, core.TxPurposeWithdraw}
+	if len(purposes) != len(want) {
+		t.Fatalf("recorded txs %v, want %v", purposes, want)
+	}
+	for i := range want {
+		if purposes[i] != want[i] {
+			t.Errorf("recorded txs %v, want %v", purposes, want)
+		}
+	}
+}
+
+func TestFakeRefund(t *testing.T) {
+	f := NewFakeClient(newMemStore())
+	if err := f.SetBalance(testUser, "1000"); err != nil {
+		t.Fatal(err)
+	}
+	payOrder(t, f)
+	assertBalance(t, f, testUser, "0")
+
+	if _, err := f.CancelOrder(testOrderID); err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, f, testUser, "1000")
+
+	orders, err := f.GetOrders([]string{testOrderID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].LockedFunds != 0 || orders[0].Status != "cancelled" {
+		t.Errorf("unexpected orders %+v", orders)
+	}
+
+	height, err := f.LatestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := f.BlockEvents(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventOrderClosed || events[0].To != testUser || events[0].Amount != "1000" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestFakeClaimTokens(t *testing.T) {
+	store := newMemStore()
+	f := NewFakeClient(store)
+
+	hash, err := f.ClaimTokens(context.Background(), 7, testUser, "400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, f, testUser, "400")
+
+	if len(store.txs) != 1 {
+		t.Fatalf("recorded %d txs, want 1", len(store.txs))
+	}
+	if tx := store.txs[0]; tx.Purpose != core.TxPurposeFaucet || tx.Account != testUser || tx.ClaimID != 7 || tx.Hash != hash {
+		t.Errorf("unexpected recorded tx %+v", tx)
+	}
+}
+
+func TestFakeError(t *testing.T) {
+	f := NewFakeClient(newMemStore())
+	f.SetError(ErrNoNode)
+
+	if _, err := f.ClaimTokens(context.Background(), 1, testUser, "400"); err != ErrNoNode {
+		t.Errorf("ClaimTokens err %v, want %v", err, ErrNoNode)
+	}
+
+	f.SetError(nil)
+	if _, err := f.ClaimTokens(context.Background(), 1, testUser, "400"); err != nil {
+		t.Errorf("ClaimTokens err %v after the error was cleared", err)
+	}
+}
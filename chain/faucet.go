@@ -0,0 +1,216 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"titan-container-platform/core"
+
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+const (
+	// maxClaimBatch is the most recipients merged into one faucet tx.
+	maxClaimBatch = 20
+	// claimBatchWindow is how long the broadcaster waits for more claims after the first one.
+	claimBatchWindow = 2 * time.Second
+)
+
+type claimResult struct {
+	hash string
+	err  error
+}
+
+// claimRequest is a faucet transfer waiting in the broadcaster queue.
+type claimRequest struct {
+	claimID int64
+	to      string
+	amount  string
+	result  chan claimResult
+
+	lock      sync.Mutex
+	taken     bool
+	cancelled bool
+}
+
+// take marks the claim as taken by a broadcaster, false when its caller gave up on it.
+func (r *claimRequest) take() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.cancelled {
+		return false
+	}
+
+	r.taken = true
+	return true
+}
+
+// cancel gives up on the claim unless a broadcaster took it, and returns whether it did.
+func (r *claimRequest) cancel() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.taken {
+		return false
+	}
+
+	r.cancelled = true
+	return true
+}
+
+// ClaimTokens queues the transfer to the specified address and waits for the
+// faucet tx that carries it to be broadcast. A claim that no broadcaster takes before
+// the ctx is done, because every wallet went inactive, fails with ErrFaucetDry. Once
+// a broadcaster took the claim, the result of its tx is waited for.
+func (c *cosmosClient) ClaimTokens(ctx context.Context, claimID int64, toAddress string, faucetToken string) (string, error) {
+	if _, err := cosmostypes.GetFromBech32(toAddress, c.prefix); err != nil {
+		return "", err
+	}
+
+	if !c.faucets.anyActive() {
+		return "", ErrFaucetDry
+	}
+
+	req := &claimRequest{claimID: claimID, to: toAddress, amount: faucetToken, result: make(chan claimResult, 1)}
+
+	select {
+	case c.claims <- req:
+	case <-ctx.Done():
+		return "", ErrFaucetDry
+	}
+
+	select {
+	case res := <-req.result:
+		return res.hash, res.err
+	case <-ctx.Done():
+	}
+
+	if req.cancel() {
+		return "", ErrFaucetDry
+	}
+
+	// a broadcaster is sending the claim, its result decides
+	res := <-req.result
+	return res.hash, res.err
+}
+
+// runFaucet is the single broadcaster of the faucet txs of a wallet. It merges the
+// claims that arrive within the batch window into one tx, so that concurrent claims
+// don't compete for the sequence of the wallet. Inactive wallets take no claims.
+func (c *cosmosClient) runFaucet(w *faucetWallet) {
+	for {
+		if !w.isActive() {
+			time.Sleep(claimBatchWindow)
+			continue
+		}
+
+		first := <-c.claims
+		if !first.take() {
+			continue
+		}
+		batch := []*claimRequest{first}
+
+		timer := time.NewTimer(claimBatchWindow)
+	collect:
+		for len(batch) < maxClaimBatch {
+			select {
+			case r := <-c.claims:
+				if r.take() {
+					batch = append(batch, r)
+				}
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		c.payClaims(w, batch)
+	}
+}
+
+// payClaims sends the claims in one tx and hands its result to each of them. A batch
+// whose gas is above the max gas is split in halves, so only a single claim over the
+// cap fails.
+func (c *cosmosClient) payClaims(w *faucetWallet, batch []*claimRequest) {
+	hash, err := c.sendClaims(w, batch)
+	if errors.Is(err, ErrGasCap) && len(batch) > 1 {
+		half := len(batch) / 2
+		c.payClaims(w, batch[:half])
+		c.payClaims(w, batch[half:])
+		return
+	}
+
+	if err != nil {
+		log.Errorf("faucet %s tx for %d claims err:%s", w.address, len(batch), err.Error())
+	}
+
+	for _, r := range batch {
+		r.result <- claimResult{hash: hash, err: err}
+	}
+}
+
+// sendClaims broadcasts one tx with a CW20 transfer per claim and a single
+// MsgMultiSend of the gas coins to every recipient.
+func (c *cosmosClient) sendClaims(w *faucetWallet, batch []*claimRequest) (string, error) {
+	faucetAddr := w.address
+
+	// 主币, 作为gas
+	gasCoins, err := cosmostypes.ParseCoinsNormalized(c.faucetGas)
+	if err != nil {
+		return "", err
+	}
+
+	msgs := make([]cosmostypes.Msg, 0, len(batch)+1)
+	outputs := make([]banktypes.Output, 0, len(batch))
+	inputCoins := cosmostypes.NewCoins()
+	tokens := new(big.Int)
+
+	for _, r := range batch {
+		amount, ok := new(big.Int).SetString(r.amount, 10)
+		if !ok {
+			return "", fmt.Errorf("invalid amount %s", r.amount)
+		}
+		tokens.Add(tokens, amount)
+
+		// 合约代币
+		tokenReq, err := executeMsg(faucetAddr, c.tokenContract, &TokenExecuteMsg{Transfer: &TransferMsg{Recipient: r.to, Amount: r.amount}})
+		if err != nil {
+			return "", err
+		}
+		msgs = append(msgs, tokenReq)
+
+		outputs = append(outputs, banktypes.Output{Address: r.to, Coins: gasCoins})
+		inputCoins = inputCoins.Add(gasCoins...)
+	}
+
+	msgs = append(msgs, &banktypes.MsgMultiSend{
+		Inputs: []banktypes.Input{{
+			Address: faucetAddr,
+			Coins:   inputCoins,
+		}},
+		Outputs: outputs,
+	})
+
+	log.Infof("Sending faucet tokens from faucet address [%s] to %d recipients", faucetAddr, len(batch))
+
+	// the tx is recorded once per recipient, so it shows in the history of each of them
+	records := make([]*core.ChainTx, 0, len(batch))
+	for _, r := range batch {
+		records = append(records, &core.ChainTx{Purpose: core.TxPurposeFaucet, Account: r.to, ClaimID: r.claimID})
+	}
+
+	hash, err := c.broadcast(w.account, records, msgs...)
+	if err != nil {
+		return hash, err
+	}
+
+	c.faucets.debit(w, inputCoins.AmountOf(gasDenom).BigInt(), tokens)
+
+	return hash, nil
+}
@@ -0,0 +1,132 @@
+package chain
+
+import (
+	"context"
+	"time"
+
+	"titan-container-platform/core"
+)
+
+const (
+	// indexInterval is how often all orders of the order contract are synced.
+	indexInterval = 10 * time.Minute
+	indexPageSize = 100
+)
+
+// Indexer mirrors the orders of the order contract into the chain_orders table. A full
+// sync runs periodically, and the orders of the order contract events are refreshed as
+// they come in between.
+type Indexer struct {
+	client Client
+	store  Store
+}
+
+// NewIndexer creates an indexer that reads the order contract with the client into the store.
+func NewIndexer(client Client, store Store) *Indexer {
+	return &Indexer{client: client, store: store}
+}
+
+// Run syncs all orders at start and then periodically until the context is done.
+func (x *Indexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(indexInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := x.sync(); err != nil {
+			log.Errorf("sync chain orders err:%s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HandleEvent refreshes the order of an order contract event. Subscribe it before the
+// handlers that read the mirrored orders, so they see the update.
+func (x *Indexer) HandleEvent(e *Event) {
+	switch e.Type {
+	case EventOrderCreated, EventOrderRenewed, EventOrderClosed, EventFundsLocked:
+	default:
+		return
+	}
+
+	if e.OrderID == "" {
+		return
+	}
+
+	list, err := x.client.GetOrders([]string{e.OrderID})
+	if err != nil {
+		log.Errorf("GetOrders %s err:%s", e.OrderID, err.Error())
+		return
+	}
+
+	x.save(list, time.Now())
+}
+
+// sync pages through all orders of the order contract, then drops the mirrored
+// orders the contract no longer has.
+func (x *Indexer) sync() error {
+	// the rows are marked with our clock, in whole seconds as the column keeps them
+	start := time.Now().Truncate(time.Second)
+	startAfter := ""
+	total := 0
+
+	for {
+		list, err := x.client.ListOrders(startAfter, indexPageSize)
+		if err != nil {
+			return err
+		}
+
+		if err := x.save(list, start); err != nil {
+			return err
+		}
+
+		total += len(list)
+		if len(list) < indexPageSize {
+			break
+		}
+		startAfter = list[len(list)-1].ID
+	}
+
+	// the orders refreshed by events meanwhile are marked later than the start and stay
+	removed, err := x.store.DeleteChainOrdersSyncedBefore(start)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("synced %d chain orders, removed %d", total, removed)
+	return nil
+}
+
+func (x *Indexer) save(list []*TokenOrder, syncedAt time.Time) error {
+	rows := make([]*core.ChainOrder, 0, len(list))
+	for _, o := range list {
+		rows = append(rows, o.row())
+	}
+
+	err := x.store.SaveChainOrders(rows, syncedAt)
+	if err != nil {
+		log.Errorf("SaveChainOrders err:%s", err.Error())
+	}
+
+	return err
+}
+
+// row converts the order into its mirrored row.
+func (o *TokenOrder) row() *core.ChainOrder {
+	return &core.ChainOrder{
+		ID:          o.ID,
+		Initiator:   o.Initiator,
+		CPU:         o.Resource.CPU,
+		Memory:      o.Resource.Memory,
+		Disk:        o.Resource.Disk,
+		Duration:    o.Duration,
+		LockedFunds: o.LockedFunds,
+		StartHeight: o.StartHeight,
+		EndHeight:   o.StartHeight + o.Duration,
+		Status:      o.Status,
+	}
+}
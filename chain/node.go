@@ -0,0 +1,267 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+	"github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	healthInterval = 10 * time.Second
+	healthTimeout  = 5 * time.Second
+	// maxHeightLag is how many blocks a node may be behind the highest node and still be used.
+	maxHeightLag = 5
+)
+
+// ErrNoNode is returned when no chain endpoint is reachable and synced.
+var ErrNoNode = errors.New("no healthy chain node")
+
+// node is a chain endpoint with its last known health. RPC nodes serve txs,
+// blocks and queries, gRPC nodes serve queries only.
+type node struct {
+	url string
+
+	lock    sync.Mutex
+	tx      *cosmosclient.Client // nil for gRPC nodes and RPC nodes not connected yet
+	conn    *grpc.ClientConn
+	query   chaintypes.QueryClient
+	bank    banktypes.QueryClient
+	healthy bool
+	height  int64
+}
+
+func (n *node) state() (bool, int64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.healthy, n.height
+}
+
+func (n *node) client() *cosmosclient.Client {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.tx
+}
+
+// ranked returns the healthy nodes, highest first.
+func ranked(nodes []*node) []*node {
+	var list []*node
+	heights := make(map[*node]int64, len(nodes))
+
+	for _, n := range nodes {
+		if healthy, height := n.state(); healthy {
+			list = append(list, n)
+			heights[n] = height
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return heights[list[i]] > heights[list[j]] })
+
+	return list
+}
+
+// rpcNode returns the healthiest RPC node.
+func (c *cosmosClient) rpcNode() (*node, error) {
+	list := ranked(c.rpcNodes)
+	if len(list) == 0 {
+		return nil, ErrNoNode
+	}
+
+	return list[0], nil
+}
+
+// withRPC calls fn with the healthy RPC nodes in turn until it succeeds.
+func (c *cosmosClient) withRPC(fn func(tc *cosmosclient.Client) error) error {
+	return failover(ranked(c.rpcNodes), func(n *node) error { return fn(n.client()) })
+}
+
+// withQuery calls fn with the healthy query nodes in turn until it succeeds. The
+// gRPC nodes are used when there are any, the RPC nodes otherwise.
+func (c *cosmosClient) withQuery(fn func(n *node) error) error {
+	nodes := c.grpcNodes
+	if len(nodes) == 0 {
+		nodes = c.rpcNodes
+	}
+
+	return failover(ranked(nodes), fn)
+}
+
+func failover(list []*node, fn func(n *node) error) error {
+	if len(list) == 0 {
+		return ErrNoNode
+	}
+
+	var err error
+	for _, n := range list {
+		if err = fn(n); err == nil {
+			return nil
+		}
+
+		log.Warnf("chain node %s err:%s", n.url, err.Error())
+	}
+
+	return err
+}
+
+// watchNodes checks the health of the nodes until the process exits, and connects
+// the RPC nodes that were down at startup.
+func (c *cosmosClient) watchNodes() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		c.checkNodes()
+	}
+}
+
+// checkNodes updates the health of every node. A node is healthy when it is
+// reachable, not catching up and close to the highest node.
+func (c *cosmosClient) checkNodes() {
+	nodes := append(append([]*node{}, c.rpcNodes...), c.grpcNodes...)
+
+	ok := make([]bool, len(nodes))
+	heights := make([]int64, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+
+			var err error
+			if n.conn != nil {
+				heights[i], err = c.checkGRPCNode(n)
+			} else {
+				heights[i], err = c.checkRPCNode(n)
+			}
+			if err != nil {
+				log.Debugf("chain node %s unhealthy: %s", n.url, err.Error())
+				return
+			}
+			ok[i] = true
+		}(i, n)
+	}
+	wg.Wait()
+
+	maxHeight := int64(0)
+	for i := range nodes {
+		if ok[i] && heights[i] > maxHeight {
+			maxHeight = heights[i]
+		}
+	}
+
+	for i, n := range nodes {
+		healthy := ok[i] && heights[i] >= maxHeight-maxHeightLag
+
+		n.lock.Lock()
+		if n.healthy != healthy {
+			log.Infof("chain node %s healthy: %v, height %d", n.url, healthy, heights[i])
+		}
+		n.healthy = healthy
+		n.height = heights[i]
+		n.lock.Unlock()
+	}
+}
+
+func (c *cosmosClient) checkRPCNode(n *node) (int64, error) {
+	tc := n.client()
+	if tc == nil {
+		// the node was down when it was last tried
+		connected, err := c.connect(n.url)
+		if err != nil {
+			return 0, err
+		}
+
+		n.lock.Lock()
+		n.tx = connected
+		n.query = chaintypes.NewQueryClient(connected.Context())
+		n.bank = banktypes.NewQueryClient(connected.Context())
+		n.lock.Unlock()
+
+		log.Infof("connected to chain node %s", n.url)
+		tc = connected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	status, err := tc.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if status.SyncInfo.CatchingUp {
+		return 0, errors.New("catching up")
+	}
+
+	return status.SyncInfo.LatestBlockHeight, nil
+}
+
+func (c *cosmosClient) checkGRPCNode(n *node) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	svc := cmtservice.NewServiceClient(n.conn)
+
+	syncing, err := svc.GetSyncing(ctx, &cmtservice.GetSyncingRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	if syncing.Syncing {
+		return 0, errors.New("catching up")
+	}
+
+	block, err := svc.GetLatestBlock(ctx, &cmtservice.GetLatestBlockRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	return block.GetSdkBlock().GetHeader().Height, nil
+}
+
+// connect creates the client of an RPC node, which fails when the node is down.
+func (c *cosmosClient) connect(url string) (*cosmosclient.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	tc, err := cosmosclient.New(ctx,
+		cosmosclient.WithAddressPrefix(c.prefix),
+		cosmosclient.WithNodeAddress(url),
+		cosmosclient.WithGasPrices(c.gasPrices),
+		cosmosclient.WithGasAdjustment(c.gasAdjustment),
+		cosmosclient.WithKeyringServiceName(c.serviceName),
+		cosmosclient.WithKeyringDir(c.keyringDir),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tc, nil
+}
+
+// newGRPCNode creates a gRPC node, the connection is made lazily.
+func newGRPCNode(url string) (*node, error) {
+	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &node{
+		url:   url,
+		conn:  conn,
+		query: chaintypes.NewQueryClient(conn),
+		bank:  banktypes.NewQueryClient(conn),
+	}, nil
+}
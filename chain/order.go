@@ -0,0 +1,115 @@
+package chain
+
+import (
+	"encoding/json"
+
+	"titan-container-platform/core"
+)
+
+// executeAsService broadcasts the contract msg from the service account.
+func (c *cosmosClient) executeAsService(contract string, msg interface{}, record *core.ChainTx) (string, error) {
+	a := c.getAccount()
+	if a == nil {
+		return "", ErrNoAccount
+	}
+
+	serviceAddr, err := a.Address(c.prefix)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := executeMsg(serviceAddr, contract, msg)
+	if err != nil {
+		return "", err
+	}
+
+	return c.broadcast(*a, []*core.ChainTx{record}, req)
+}
+
+// RenewOrder extends the order on the order contract by the blocks, paid from the owner's tokens.
+// The owner must have granted the service account a CW20 allowance for the amount.
+func (c *cosmosClient) RenewOrder(owner, id string, blocks uint64, coin string) (string, error) {
+	msg, err := renewOrderMsg(c.orderContract, owner, id, blocks, coin)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("RenewOrder %s from owner %s", id, owner)
+
+	record := &core.ChainTx{Purpose: core.TxPurposeOrderRenewal, Account: owner, OrderID: id}
+	return c.executeAsService(c.tokenContract, msg, record)
+}
+
+// renewOrderMsg builds the CW20 send from the owner to the order contract that extends the order.
+func renewOrderMsg(orderContract, owner, id string, blocks uint64, coin string) (*TokenExecuteMsg, error) {
+	renew := &OrderExecuteMsg{RenewOrder: &RenewOrderMsg{OrderID: id, Duration: blocks}}
+
+	orderJSONBody, err := json.Marshal(renew)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenExecuteMsg{SendFrom: &SendFromMsg{Owner: owner, Contract: orderContract, Amount: coin, Msg: orderJSONBody}}, nil
+}
+
+// CancelOrder closes the order on the order contract and refunds its unsettled funds to the initiator.
+func (c *cosmosClient) CancelOrder(id string) (string, error) {
+	log.Infof("CancelOrder %s", id)
+
+	msg := &OrderExecuteMsg{CancelOrder: &CancelOrderMsg{OrderID: id}}
+	record := &core.ChainTx{Purpose: core.TxPurposeOrderCancel, OrderID: id}
+	return c.executeAsService(c.orderContract, msg, record)
+}
+
+// SettleOrder releases the funds of the elapsed blocks of the order to the provider.
+func (c *cosmosClient) SettleOrder(id string) (string, error) {
+	msg := &OrderExecuteMsg{SettleOrder: &SettleOrderMsg{OrderID: id}}
+	record := &core.ChainTx{Purpose: core.TxPurposeOrderSettle, OrderID: id}
+	return c.executeAsService(c.orderContract, msg, record)
+}
+
+// Withdraw transfers the amount of settled funds from the order contract to the provider.
+func (c *cosmosClient) Withdraw(amount string) (string, error) {
+	log.Infof("Withdraw %s", amount)
+
+	msg := &OrderExecuteMsg{Withdraw: &WithdrawMsg{Amount: amount}}
+	record := &core.ChainTx{Purpose: core.TxPurposeWithdraw}
+	return c.executeAsService(c.orderContract, msg, record)
+}
+
+// GetOrders retrieves orders based on the provided order IDs.
+func (c *cosmosClient) GetOrders(ids []string) ([]*TokenOrder, error) {
+	var list []*TokenOrder
+	err := c.queryContract(c.orderContract, &OrderQueryMsg{Orders: &OrdersQuery{OrderIDs: ids}}, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// OrdersByInitiator retrieves a page of the orders of the initiator, after the order with the id startAfter.
+func (c *cosmosClient) OrdersByInitiator(initiator, startAfter string, limit int) ([]*TokenOrder, error) {
+	query := &OrderQueryMsg{OrdersByInitiator: &OrdersByInitiatorQuery{Initiator: initiator, StartAfter: startAfter, Limit: limit}}
+
+	var list []*TokenOrder
+	err := c.queryContract(c.orderContract, query, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ListOrders retrieves a page of all orders, after the order with the id startAfter.
+func (c *cosmosClient) ListOrders(startAfter string, limit int) ([]*TokenOrder, error) {
+	query := &OrderQueryMsg{ListOrders: &ListOrdersQuery{StartAfter: startAfter, Limit: limit}}
+
+	var list []*TokenOrder
+	err := c.queryContract(c.orderContract, query, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
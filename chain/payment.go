@@ -0,0 +1,73 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrPaymentMismatch is returned when a signed tx does not carry the expected order payment msgs.
+var ErrPaymentMismatch = errors.New("tx does not carry the order payment")
+
+func orderPaymentMsg(tokenContract, orderContract, sender, id string, cpu, memory, disk int, blocks uint64, coin string) (*chaintypes.MsgExecuteContract, error) {
+	create := &OrderExecuteMsg{CreateOrder: &CreateOrderMsg{
+		OrderID:  id,
+		CPU:      cpu,
+		Memory:   memory,
+		Disk:     disk,
+		Duration: blocks,
+	}}
+
+	orderJSONBody, err := json.Marshal(create)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeMsg(sender, tokenContract, &TokenExecuteMsg{Send: &SendMsg{Contract: orderContract, Amount: coin, Msg: orderJSONBody}})
+}
+
+// allowanceMsg builds the CW20 increase_allowance with which the owner lets the spender
+// send the amount of its tokens.
+func allowanceMsg(tokenContract, owner, spender, amount string) (*chaintypes.MsgExecuteContract, error) {
+	return executeMsg(owner, tokenContract, &TokenExecuteMsg{IncreaseAllowance: &IncreaseAllowanceMsg{Spender: spender, Amount: amount}})
+}
+
+// carriesPayment reports whether the msgs of a tx carry each of the payment msgs.
+func carriesPayment(msgs []cosmostypes.Msg, payment []*chaintypes.MsgExecuteContract) bool {
+	for _, p := range payment {
+		found := false
+		for _, msg := range msgs {
+			if m, ok := msg.(*chaintypes.MsgExecuteContract); ok && samePayment(m, p) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return len(payment) > 0
+}
+
+// samePayment reports whether the msgs execute the same contract call from the same sender,
+// comparing the contract msgs as JSON values so key order and whitespace don't matter.
+func samePayment(a, b *chaintypes.MsgExecuteContract) bool {
+	if a.Sender != b.Sender || a.Contract != b.Contract {
+		return false
+	}
+
+	var av, bv interface{}
+	if err := json.Unmarshal(a.Msg, &av); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b.Msg, &bv); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(av, bv)
+}
@@ -0,0 +1,244 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosaccount"
+)
+
+const (
+	gasDenom = "uttnt"
+	// balanceInterval is how often the balances of the faucet wallets are refreshed.
+	balanceInterval = time.Minute
+)
+
+// ErrFaucetDry is returned when no faucet wallet has enough balance left for a claim.
+var ErrFaucetDry = errors.New("no faucet wallet has enough balance")
+
+// FaucetBalance represents the balances of a faucet wallet.
+type FaucetBalance struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Gas     string `json:"gas"`    // uttnt
+	Token   string `json:"token"`  // CW20 tokens
+	Active  bool   `json:"active"` // false when a balance is under its threshold
+}
+
+// faucetWallet is a keyring account that pays faucet claims.
+type faucetWallet struct {
+	account cosmosaccount.Account
+	address string
+
+	lock    sync.Mutex
+	gas     *big.Int
+	token   *big.Int
+	active  bool
+	fetched bool // the balances were queried at least once
+}
+
+func (w *faucetWallet) isActive() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.active
+}
+
+func (w *faucetWallet) balance() *FaucetBalance {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return &FaucetBalance{
+		Name:    w.account.Name,
+		Address: w.address,
+		Gas:     w.gas.String(),
+		Token:   w.token.String(),
+		Active:  w.active,
+	}
+}
+
+// faucetPool spreads the faucet claims across several wallets and skips the ones that run low.
+type faucetPool struct {
+	wallets []*faucetWallet
+
+	minGas     *big.Int
+	minToken   *big.Int
+	alertFloor *big.Int
+	alertURL   string
+	alerted    bool
+}
+
+// newFaucetPool loads the faucet wallets from the keyring. The wallets start
+// inactive until their balances are first refreshed.
+func (c *cosmosClient) newFaucetPool(names []string, minGas, minToken, alertFloor int64, alertURL string) (*faucetPool, error) {
+	if len(names) == 0 {
+		names = []string{c.serviceName}
+	}
+
+	pool := &faucetPool{
+		minGas:     big.NewInt(minGas),
+		minToken:   big.NewInt(minToken),
+		alertFloor: big.NewInt(alertFloor),
+		alertURL:   alertURL,
+	}
+
+	for _, name := range names {
+		acc, err := c.accounts.GetByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("faucet key %s: %w", name, err)
+		}
+
+		addr, err := acc.Address(c.prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		pool.wallets = append(pool.wallets, &faucetWallet{account: acc, address: addr, gas: new(big.Int), token: new(big.Int)})
+	}
+
+	return pool, nil
+}
+
+func (p *faucetPool) anyActive() bool {
+	for _, w := range p.wallets {
+		if w.isActive() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchFaucets refreshes the balances of the faucet wallets until the client is closed.
+func (c *cosmosClient) watchFaucets() {
+	ticker := time.NewTicker(balanceInterval)
+	defer ticker.Stop()
+
+	for {
+		c.refreshFaucets()
+		<-ticker.C
+	}
+}
+
+// refreshFaucets queries the balances of the faucet wallets, activates the ones
+// above the thresholds and alerts when the total token balance is under the floor.
+// A wallet whose query fails keeps its last known balances.
+func (c *cosmosClient) refreshFaucets() {
+	p := c.faucets
+	total := new(big.Int)
+	known := true
+
+	for _, w := range p.wallets {
+		gas, token, err := c.walletBalances(w.address)
+
+		w.lock.Lock()
+		if err != nil {
+			log.Errorf("faucet %s balances err:%s", w.address, err.Error())
+		} else {
+			w.gas = gas
+			w.token = token
+			w.fetched = true
+
+			active := w.gas.Cmp(p.minGas) >= 0 && w.token.Cmp(p.minToken) >= 0
+			if w.active && !active {
+				log.Warnf("faucet %s is low, gas %s token %s", w.address, w.gas, w.token)
+			}
+			w.active = active
+		}
+
+		total.Add(total, w.token)
+		known = known && w.fetched
+		w.lock.Unlock()
+	}
+
+	if !known {
+		// the total is unknown until every wallet was queried
+		return
+	}
+
+	if total.Cmp(p.alertFloor) >= 0 {
+		p.alerted = false
+		return
+	}
+
+	if !p.alerted {
+		p.alerted = true
+		alert(p.alertURL, fmt.Sprintf("faucet token balance %s is below the floor %s", total, p.alertFloor))
+	}
+}
+
+// walletBalances queries the uttnt and CW20 token balances of the address.
+func (c *cosmosClient) walletBalances(address string) (*big.Int, *big.Int, error) {
+	native, err := c.GetNativeBalance(address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gas, ok := new(big.Int).SetString(native, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid gas balance %s", native)
+	}
+
+	balance, err := c.GetBalance(address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, ok := new(big.Int).SetString(balance, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid token balance %s", balance)
+	}
+
+	return gas, token, nil
+}
+
+// debit takes the amounts paid out by a faucet tx off the cached balances of the
+// wallet, so a wallet running dry stops taking claims before the next refresh.
+func (p *faucetPool) debit(w *faucetWallet, gas, token *big.Int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.gas = new(big.Int).Sub(w.gas, gas)
+	w.token = new(big.Int).Sub(w.token, token)
+	if w.gas.Cmp(p.minGas) < 0 || w.token.Cmp(p.minToken) < 0 {
+		w.active = false
+	}
+}
+
+// FaucetBalances returns the cached balances of the faucet wallets.
+func (c *cosmosClient) FaucetBalances() ([]*FaucetBalance, error) {
+	list := make([]*FaucetBalance, 0, len(c.faucets.wallets))
+	for _, w := range c.faucets.wallets {
+		list = append(list, w.balance())
+	}
+
+	return list, nil
+}
+
+// alert posts the message to the alert webhook, or only logs it when there is none.
+func alert(url, msg string) {
+	log.Warnf("alert: %s", msg)
+
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(map[string]string{"text": msg})
+	if err != nil {
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Errorf("alert webhook err:%s", err.Error())
+		return
+	}
+	resp.Body.Close()
+}
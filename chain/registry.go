@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"fmt"
+
+	"titan-container-platform/config"
+)
+
+const (
+	nativeSymbol   = "TTNT"
+	nativeDecimals = 6
+	// coinType is the BIP44 coin type of the cosmos keys.
+	coinType = 118
+
+	defaultTokenSymbol     = "TOKEN"
+	defaultTokenDecimals   = 6
+	defaultGasPriceLow     = 0.001
+	defaultGasPriceAverage = 0.0025
+	defaultGasPriceHigh    = 0.004
+)
+
+// ChainInfo describes the chain in the format of the Keplr experimentalSuggestChain call.
+type ChainInfo struct {
+	ChainID       string        `json:"chainId"`
+	ChainName     string        `json:"chainName"`
+	RPC           string        `json:"rpc"`
+	REST          string        `json:"rest"`
+	BIP44         BIP44         `json:"bip44"`
+	Bech32Config  Bech32Config  `json:"bech32Config"`
+	Currencies    []Currency    `json:"currencies"`
+	FeeCurrencies []FeeCurrency `json:"feeCurrencies"`
+	StakeCurrency Currency      `json:"stakeCurrency"`
+	Features      []string      `json:"features"`
+}
+
+// BIP44 holds the coin type the wallet derives the keys with.
+type BIP44 struct {
+	CoinType int `json:"coinType"`
+}
+
+// Bech32Config holds the bech32 prefixes of the addresses and keys.
+type Bech32Config struct {
+	AccAddr  string `json:"bech32PrefixAccAddr"`
+	AccPub   string `json:"bech32PrefixAccPub"`
+	ValAddr  string `json:"bech32PrefixValAddr"`
+	ValPub   string `json:"bech32PrefixValPub"`
+	ConsAddr string `json:"bech32PrefixConsAddr"`
+	ConsPub  string `json:"bech32PrefixConsPub"`
+}
+
+// Currency is a coin of the chain, a CW20 token has its contract address set.
+type Currency struct {
+	CoinDenom        string `json:"coinDenom"`
+	CoinMinimalDenom string `json:"coinMinimalDenom"`
+	CoinDecimals     int    `json:"coinDecimals"`
+	Type             string `json:"type,omitempty"`
+	ContractAddress  string `json:"contractAddress,omitempty"`
+}
+
+// FeeCurrency is a coin that pays the gas, with the gas prices offered by the wallet.
+type FeeCurrency struct {
+	Currency
+	GasPriceStep GasPriceStep `json:"gasPriceStep"`
+}
+
+// GasPriceStep holds the low, average and high gas prices.
+type GasPriceStep struct {
+	Low     float64 `json:"low"`
+	Average float64 `json:"average"`
+	High    float64 `json:"high"`
+}
+
+// NewChainInfo builds the chain info of the configuration.
+func NewChainInfo(cfg *config.ChainAPIConfig) *ChainInfo {
+	prefix := cfg.AddressPrefix
+
+	rpc := cfg.PublicRPC
+	if rpc == "" {
+		rpc = cfg.RPC
+	}
+	if rpc == "" && len(cfg.RPCs) > 0 {
+		rpc = cfg.RPCs[0]
+	}
+
+	symbol := cfg.TokenSymbol
+	if symbol == "" {
+		symbol = defaultTokenSymbol
+	}
+
+	decimals := cfg.TokenDecimals
+	if decimals == 0 {
+		decimals = defaultTokenDecimals
+	}
+
+	step := GasPriceStep{Low: cfg.GasPriceLow, Average: cfg.GasPriceAverage, High: cfg.GasPriceHigh}
+	if step.Low == 0 {
+		step.Low = defaultGasPriceLow
+	}
+	if step.Average == 0 {
+		step.Average = defaultGasPriceAverage
+	}
+	if step.High == 0 {
+		step.High = defaultGasPriceHigh
+	}
+
+	native := Currency{CoinDenom: nativeSymbol, CoinMinimalDenom: gasDenom, CoinDecimals: nativeDecimals}
+	token := Currency{
+		CoinDenom:        symbol,
+		CoinMinimalDenom: fmt.Sprintf("cw20:%s:%s", cfg.TokenContractAddress, symbol),
+		CoinDecimals:     decimals,
+		Type:             "cw20",
+		ContractAddress:  cfg.TokenContractAddress,
+	}
+
+	return &ChainInfo{
+		ChainID:   cfg.ChainID,
+		ChainName: cfg.ChainName,
+		RPC:       rpc,
+		REST:      cfg.PublicREST,
+		BIP44:     BIP44{CoinType: coinType},
+		Bech32Config: Bech32Config{
+			AccAddr:  prefix,
+			AccPub:   prefix + "pub",
+			ValAddr:  prefix + "valoper",
+			ValPub:   prefix + "valoperpub",
+			ConsAddr: prefix + "valcons",
+			ConsPub:  prefix + "valconspub",
+		},
+		Currencies:    []Currency{native, token},
+		FeeCurrencies: []FeeCurrency{{Currency: native, GasPriceStep: step}},
+		StakeCurrency: native,
+		Features:      []string{"cosmwasm"},
+	}
+}
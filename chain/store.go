@@ -0,0 +1,26 @@
+package chain
+
+import (
+	"time"
+
+	"titan-container-platform/core"
+)
+
+// Store keeps the broadcast txs, the followed heights and the mirrored orders of the
+// chain, so the package reads and writes them without depending on the database.
+type Store interface {
+	// CreateChainTxs saves the records of a tx before it is broadcast, all or none.
+	CreateChainTxs(list []*core.ChainTx) error
+	// UpdateChainTxResult saves the result of a broadcast tx.
+	UpdateChainTxResult(tx *core.ChainTx) error
+	// LoadChainTxsByStatus retrieves the broadcast txs with the status.
+	LoadChainTxsByStatus(status core.TxStatus) ([]*core.ChainTx, error)
+	// GetSyncHeight retrieves the last block height processed by the named follower, 0 if it never ran.
+	GetSyncHeight(name string) (int64, error)
+	// SetSyncHeight saves the last block height processed by the named follower.
+	SetSyncHeight(name string, height int64) error
+	// SaveChainOrders inserts or updates the mirrored orders and marks them synced at the time.
+	SaveChainOrders(list []*core.ChainOrder, syncedAt time.Time) error
+	// DeleteChainOrdersSyncedBefore removes the mirrored orders last synced before the time.
+	DeleteChainOrdersSyncedBefore(t time.Time) (int64, error)
+}
@@ -0,0 +1,91 @@
+package chain
+
+import (
+	"context"
+	"time"
+
+	"titan-container-platform/core"
+)
+
+const (
+	trackInterval = 5 * time.Second
+	// txTimeout is how long a pending tx may stay out of a block before it is marked failed.
+	txTimeout = 10 * time.Minute
+)
+
+// Tracker polls the pending txs until the chain includes or drops them.
+type Tracker struct {
+	client Client
+	store  Store
+}
+
+// NewTracker creates a tracker that looks up the txs of the store with the client.
+func NewTracker(client Client, store Store) *Tracker {
+	return &Tracker{client: client, store: store}
+}
+
+// Run checks the pending txs until the context is done.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(trackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.poll()
+		}
+	}
+}
+
+func (t *Tracker) poll() {
+	list, err := t.store.LoadChainTxsByStatus(core.TxStatusPending)
+	if err != nil {
+		log.Errorf("LoadChainTxsByStatus err:%s", err.Error())
+		return
+	}
+
+	checked := make(map[string]bool, len(list))
+	for _, info := range list {
+		// a faucet tx has a record per recipient, updating one updates them all
+		if checked[info.Hash] {
+			continue
+		}
+		checked[info.Hash] = true
+
+		res, err := t.client.GetTx(info.Hash)
+		if err == ErrTxNotFound {
+			if time.Since(info.CreatedAt) > txTimeout {
+				log.Warnf("%s tx %s was not included in %s", info.Purpose, info.Hash, txTimeout)
+
+				info.Status = core.TxStatusFailed
+				info.Log = ErrTxNotFound.Error()
+				t.update(info)
+			}
+			continue
+		}
+		if err != nil {
+			log.Errorf("GetTx %s err:%s", info.Hash, err.Error())
+			continue
+		}
+
+		info.Status = core.TxStatusConfirmed
+		if res.Code != 0 {
+			log.Warnf("%s tx %s failed with code %d: %s", info.Purpose, info.Hash, res.Code, res.Log)
+			info.Status = core.TxStatusFailed
+		}
+		info.Code = res.Code
+		info.GasUsed = res.GasUsed
+		info.Height = res.Height
+		info.Log = res.Log
+		t.update(info)
+	}
+}
+
+func (t *Tracker) update(info *core.ChainTx) {
+	err := t.store.UpdateChainTxResult(info)
+	if err != nil {
+		log.Errorf("UpdateChainTxResult %s err:%s", info.Hash, err.Error())
+	}
+}
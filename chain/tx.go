@@ -0,0 +1,294 @@
+package chain
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"titan-container-platform/core"
+
+	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosaccount"
+	"github.com/ignite/cli/v28/ignite/pkg/cosmosclient"
+)
+
+const (
+	defaultGasPrices     = "0.0025uttnt"
+	defaultGasAdjustment = 1.3
+	defaultMaxGas        = 3000000
+)
+
+var (
+	// ErrTxNotFound is returned when the chain has not included a tx.
+	ErrTxNotFound = errors.New("tx not found")
+	// ErrGasCap is returned when the simulated gas of a tx is above the max gas.
+	ErrGasCap = errors.New("tx gas is above the max gas")
+)
+
+// TxResult represents the result of a tx included in a block.
+type TxResult struct {
+	Hash    string
+	Height  int64
+	Code    uint32
+	GasUsed int64
+	Log     string
+	// Events are the decoded contract events of the tx, without its block, hash and memo.
+	Events []*Event
+}
+
+// sequencer hands out the sequences of an account locally, so that its txs can
+// be broadcast back to back without waiting for each one to be included.
+type sequencer struct {
+	lock   sync.Mutex
+	loaded bool
+	number uint64
+	next   uint64
+}
+
+// sequencer returns the sequencer of the keyring account with the name.
+func (c *cosmosClient) sequencer(name string) *sequencer {
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	seq, ok := c.sequencers[name]
+	if !ok {
+		seq = &sequencer{}
+		c.sequencers[name] = seq
+	}
+
+	return seq
+}
+
+// broadcast signs the msgs with the account and broadcasts them without waiting for
+// the tx to be included. The records of the tx are saved as pending before it is
+// broadcast, so a tx that is not recorded is never sent, and the Tracker confirms it.
+func (c *cosmosClient) broadcast(account cosmosaccount.Account, records []*core.ChainTx, msgs ...cosmostypes.Msg) (string, error) {
+	addr, err := account.Record.GetAddress()
+	if err != nil {
+		return "", err
+	}
+
+	list := ranked(c.rpcNodes)
+	if len(list) == 0 {
+		return "", ErrNoNode
+	}
+
+	seq := c.sequencer(account.Name)
+	seq.lock.Lock()
+	defer seq.lock.Unlock()
+
+	var res *cosmostypes.TxResponse
+	for retried := false; ; retried = true {
+		res = nil
+
+		var txBytes []byte
+		txBytes, err = c.signTx(list, account.Name, addr, seq, msgs...)
+		if err == nil {
+			res, err = c.sendTx(txBytes, records)
+		}
+		if retried || !wrongSequence(res, err) {
+			break
+		}
+
+		// another client used the account, start over from the chain sequence
+		log.Warnf("account %s sequence %d mismatch, reloading", addr, seq.next)
+		if res != nil {
+			c.failTx(records, res)
+		}
+		seq.loaded = false
+	}
+	if err != nil {
+		seq.loaded = false
+		return "", err
+	}
+
+	if res.Code == 0 {
+		seq.next++
+	}
+
+	return c.finishBroadcast(records, res)
+}
+
+// signTx signs the msgs with the next local sequence of the account, on the nodes of
+// the list in turn while they cannot be reached. The caller must hold the sequencer lock.
+func (c *cosmosClient) signTx(list []*node, name string, addr cosmostypes.AccAddress, seq *sequencer, msgs ...cosmostypes.Msg) ([]byte, error) {
+	var txBytes []byte
+	var err error
+	for _, n := range list {
+		tc := n.client()
+		clientCtx := tc.Context().WithFromName(name).WithFromAddress(addr)
+
+		txBytes, err = c.sign(tc, clientCtx, seq, msgs...)
+		if err == nil || !transportError(err) {
+			return txBytes, err
+		}
+
+		log.Warnf("chain node %s err:%s, signing on the next node", n.url, err.Error())
+	}
+
+	return nil, err
+}
+
+// sendTx saves the records of the signed tx as pending and broadcasts it in sync mode.
+// The same bytes go to the next node when one fails, so the tx is included at most once.
+func (c *cosmosClient) sendTx(txBytes []byte, records []*core.ChainTx) (*cosmostypes.TxResponse, error) {
+	hash := fmt.Sprintf("%X", cmttypes.Tx(txBytes).Hash())
+	for _, record := range records {
+		record.Hash = hash
+		record.Status = core.TxStatusPending
+	}
+
+	err := c.store.CreateChainTxs(records)
+	if err != nil {
+		return nil, fmt.Errorf("record %s tx %s: %w", records[0].Purpose, hash, err)
+	}
+
+	// a node rejecting the tx answers with a response, so errors are the node's and the next one is tried
+	var res *cosmostypes.TxResponse
+	err = c.withRPC(func(tc *cosmosclient.Client) (err error) {
+		res, err = tc.Context().BroadcastTxSync(txBytes)
+		return err
+	})
+	if err != nil {
+		// the tx may still have reached a node, the Tracker fails it if it is never included
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// wrongSequence reports whether the tx was rejected for the sequence of its account, which
+// the simulation of the gas reports before CheckTx does.
+func wrongSequence(res *cosmostypes.TxResponse, err error) bool {
+	if err != nil {
+		return strings.Contains(err.Error(), sdkerrors.ErrWrongSequence.Error())
+	}
+
+	return res.Code == sdkerrors.ErrWrongSequence.ABCICode() && res.Codespace == sdkerrors.ErrWrongSequence.Codespace()
+}
+
+// transportError reports whether the error comes from reaching the node rather than from
+// the tx, so the tx may go through another node.
+func transportError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// the RPC client wraps the failed http requests without keeping the cause on some paths
+	msg := err.Error()
+	return strings.Contains(msg, "post failed") || strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset") || strings.Contains(msg, "no such host")
+}
+
+// sign signs the msgs with the next local sequence and the simulated gas, and
+// encodes the tx. The caller must hold the sequencer lock.
+func (c *cosmosClient) sign(tc *cosmosclient.Client, clientCtx client.Context, seq *sequencer, msgs ...cosmostypes.Msg) ([]byte, error) {
+	if !seq.loaded {
+		num, next, err := clientCtx.AccountRetriever.GetAccountNumberSequence(clientCtx, clientCtx.GetFromAddress())
+		if err != nil {
+			return nil, err
+		}
+
+		seq.number, seq.next, seq.loaded = num, next, true
+	}
+
+	txf := tc.TxFactory.
+		WithAccountNumber(seq.number).
+		WithSequence(seq.next).
+		WithFromName(clientCtx.GetFromName()).
+		WithSimulateAndExecute(true).
+		WithGasAdjustment(c.gasAdjustment).
+		WithGasPrices(c.gasPrices)
+
+	_, gas, err := tx.CalculateGas(clientCtx, txf, msgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if gas > c.maxGas {
+		return nil, fmt.Errorf("%w: %d > %d", ErrGasCap, gas, c.maxGas)
+	}
+
+	txf = txf.WithGas(gas)
+
+	txBuilder, err := txf.BuildUnsignedTx(msgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Sign(context.Background(), txf, clientCtx.GetFromName(), txBuilder, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientCtx.TxConfig.TxEncoder()(txBuilder.GetTx())
+}
+
+// finishBroadcast logs the tx of a sync broadcast response. A tx rejected by CheckTx
+// is marked failed and returned with an error.
+func (c *cosmosClient) finishBroadcast(records []*core.ChainTx, res *cosmostypes.TxResponse) (string, error) {
+	purpose := records[0].Purpose
+	if res.Code != 0 {
+		c.failTx(records, res)
+		return res.TxHash, fmt.Errorf("broadcast %s tx code %d: %s", purpose, res.Code, res.RawLog)
+	}
+
+	log.Infof("broadcast %s tx %s", purpose, res.TxHash)
+
+	return res.TxHash, nil
+}
+
+// failTx marks the records of a tx that CheckTx rejected as failed.
+func (c *cosmosClient) failTx(records []*core.ChainTx, res *cosmostypes.TxResponse) {
+	for _, record := range records {
+		record.Status = core.TxStatusFailed
+		record.Code = res.Code
+		record.Log = res.RawLog
+	}
+
+	// the records of a tx share its hash, one update fails them all
+	err := c.store.UpdateChainTxResult(records[0])
+	if err != nil {
+		log.Errorf("UpdateChainTxResult %s err:%s", records[0].Hash, err.Error())
+	}
+}
+
+// GetTx returns the result of the tx with the hash, or ErrTxNotFound if it is not included yet.
+func (c *cosmosClient) GetTx(hash string) (*TxResult, error) {
+	b, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *ctypes.ResultTx
+	err = c.withRPC(func(tc *cosmosclient.Client) (err error) {
+		res, err = tc.RPC.Tx(context.Background(), b, false)
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, ErrTxNotFound
+		}
+		return nil, err
+	}
+
+	return &TxResult{
+		Hash:    hash,
+		Height:  res.Height,
+		Code:    res.TxResult.Code,
+		GasUsed: res.TxResult.GasUsed,
+		Log:     res.TxResult.Log,
+		Events:  c.decodeTxEvents(wasmAttributes(res.TxResult.Events)),
+	}, nil
+}
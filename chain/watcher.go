@@ -0,0 +1,109 @@
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	watchInterval    = 3 * time.Second
+	maxBlocksPerPoll = 50
+	// contractWatcher is the name the processed height is stored under.
+	contractWatcher = "contract_events"
+)
+
+// Watcher follows new blocks and publishes the decoded contract events to its subscribers.
+// Events are delivered at least once, so handlers must be idempotent.
+type Watcher struct {
+	client Client
+	store  Store
+
+	lock     sync.RWMutex
+	handlers []func(*Event)
+}
+
+// NewWatcher creates a watcher of the contract events on the client's chain, which keeps
+// the processed height in the store.
+func NewWatcher(client Client, store Store) *Watcher {
+	return &Watcher{client: client, store: store}
+}
+
+// Subscribe registers a handler that is called for every event, in block order.
+func (w *Watcher) Subscribe(fn func(*Event)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.handlers = append(w.handlers, fn)
+}
+
+// Run follows the chain from the last processed height until the context is done.
+func (w *Watcher) Run(ctx context.Context) {
+	height, err := w.store.GetSyncHeight(contractWatcher)
+	if err != nil {
+		log.Errorf("GetSyncHeight err:%s", err.Error())
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		height = w.poll(height)
+	}
+}
+
+// poll publishes the events of the blocks after height and returns the last processed height.
+func (w *Watcher) poll(height int64) int64 {
+	latest, err := w.client.LatestHeight()
+	if err != nil {
+		log.Errorf("LatestHeight err:%s", err.Error())
+		return height
+	}
+
+	// start at the tip the first time instead of replaying the whole chain
+	if height == 0 {
+		w.saveHeight(latest)
+		return latest
+	}
+
+	for h := height + 1; h <= latest && h <= height+maxBlocksPerPoll; h++ {
+		events, err := w.client.BlockEvents(h)
+		if err != nil {
+			log.Errorf("BlockEvents %d err:%s", h, err.Error())
+			return h - 1
+		}
+
+		w.publish(events)
+		w.saveHeight(h)
+	}
+
+	if latest > height+maxBlocksPerPoll {
+		return height + maxBlocksPerPoll
+	}
+
+	return latest
+}
+
+func (w *Watcher) publish(events []*Event) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	for _, e := range events {
+		for _, fn := range w.handlers {
+			fn(e)
+		}
+	}
+}
+
+func (w *Watcher) saveHeight(height int64) {
+	err := w.store.SetSyncHeight(contractWatcher, height)
+	if err != nil {
+		log.Errorf("SetSyncHeight err:%s", err.Error())
+	}
+}
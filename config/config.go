@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Cfg holds the configuration settings for the application.
 var Cfg Config
 
@@ -9,9 +15,34 @@ type Config struct {
 	Listen      string
 	DatabaseURL string
 	SecretKey   string
+	Admins      []string // wallet accounts allowed on the admin endpoints
 
 	KubesphereAPI KubesphereAPIConfig
 	ChainAPI      ChainAPIConfig
+	Pricing       PricingConfig
+	Captcha       CaptchaConfig
+
+	Network  string                    // profile of Networks to run on, ChainAPI when empty
+	Networks map[string]ChainAPIConfig // network profiles by name
+}
+
+// SelectNetwork makes the named network profile the chain configuration. An empty
+// name keeps ChainAPI, whose network has an empty name.
+func (c *Config) SelectNetwork(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	// viper lowercases the map keys
+	profile, ok := c.Networks[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("network profile %s not found", name)
+	}
+
+	profile.Network = strings.ToLower(name)
+	c.Network = profile.Network
+	c.ChainAPI = profile
+	return nil
 }
 
 // KubesphereAPIConfig holds the configuration for the KubeSphere API.
@@ -22,13 +53,72 @@ type KubesphereAPIConfig struct {
 	Cluster  string
 }
 
-// ChainAPIConfig holds the configuration for the chain API.
+// ChainAPIConfig holds the configuration for the chain API of a network.
 type ChainAPIConfig struct {
+	Network              string `mapstructure:"-"` // name of the selected profile, orders are recorded under it
+	Testnet              bool   // the faucet only runs on test networks
 	AddressPrefix        string
-	RPC                  string
+	RPC                  string   // ignored when RPCs is set
+	RPCs                 []string // RPC nodes to fail over between, RPC alone when empty
+	GRPCs                []string // gRPC nodes for queries, the RPC nodes serve them when empty
 	TokenContractAddress string
 	ServiceName          string
 	KeyringDir           string
 	FaucetGas            string
 	OrderContractAddress string
+	DepositAddress       string // orders can be paid by a token transfer here with the order id as memo
+	Fake                 bool   // use an in-memory chain instead of the RPC node, for local runs
+
+	GasPrices     string  // "0.0025uttnt" when empty
+	GasAdjustment float64 // multiplies the simulated gas of every tx, 1.3 when zero
+	MaxGas        uint64  // txs whose adjusted gas is above it are not broadcast, 3000000 when zero
+
+	FaucetKeys       []string // keyring accounts that pay faucet claims, ServiceName when empty
+	FaucetMinGas     int64    // uttnt under which a faucet wallet is skipped
+	FaucetMinToken   int64    // CW20 tokens under which a faucet wallet is skipped
+	FaucetAlertFloor int64    // total CW20 tokens of the faucet wallets under which an alert fires
+	AlertURL         string   // webhook the alerts are posted to
+
+	// faucet policy, windows are aligned to UTC
+	FaucetBudget      int64         // CW20 tokens given out by all claims in a window, 10000 when zero
+	FaucetWindow      time.Duration // length of the budget windows, 1h when zero
+	FaucetAmount      int64         // CW20 tokens per claim, 400 when zero
+	FaucetCooldown    time.Duration // time an account waits between claims, 24h when zero
+	FaucetLifetimeCap int64         // CW20 tokens an account may claim in total, no cap when zero
+
+	// served to wallets by /chain/info
+	ChainID         string
+	ChainName       string
+	PublicRPC       string  // RPC URL for browsers, RPC or the first of RPCs when empty
+	PublicREST      string  // REST URL for browsers
+	TokenSymbol     string  // display symbol of the CW20 token
+	TokenDecimals   int     // decimals of the CW20 token
+	GasPriceLow     float64 // uttnt per gas, the gas price steps offered by wallets
+	GasPriceAverage float64
+	GasPriceHigh    float64
+}
+
+// PricingConfig holds the configuration for order pricing.
+type PricingConfig struct {
+	ModelFile      string // pricing model file, the built-in model is used when empty
+	SurgeEnabled   bool
+	ClusterCPU     int // total cpu cores of the cluster
+	ClusterRAM     int // total ram of the cluster, in GB
+	SurgeThreshold int // allocation percent above which the surge starts
+	MaxSurgeFactor int // in percent, 200 means the rates are doubled at most
+}
+
+// CaptchaConfig holds the configuration for the captchas required by the faucet. It is only
+// used on test networks, where the faucet runs.
+type CaptchaConfig struct {
+	ResourcePath   string   // directory holding the resources directory of aj-captcha-go, "./" when empty
+	CacheType      string   // "mem" or "redis", "mem" when empty
+	CacheSize      int      // captchas kept by the mem cache, 10000 when zero
+	CacheExpireSec int      // seconds a captcha stays valid, 120 when zero
+	Offset         int      // pixels a block puzzle answer may be off, 10 when zero
+	RedisAddrs     []string // redis nodes of the redis cache
+	RedisUser      string
+	RedisPassword  string
+	RedisCluster   bool
+	RedisDB        int
 }
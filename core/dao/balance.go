@@ -0,0 +1,139 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"titan-container-platform/core"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// GetAccountBalance retrieves the prepaid balance of an account on the network, which is 0 when it has never topped up.
+func GetAccountBalance(account string) (int, error) {
+	query := fmt.Sprintf(`SELECT balance FROM %s WHERE account = ? AND network = ? `, balancesTable)
+
+	var balance int
+	err := mDB.QueryRow(query, account, network).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return balance, err
+}
+
+// RecordUsage saves the usage record and debits its cost from the account balance on the network in one
+// transaction. It returns the remaining balance, and false if the order was already billed for the hour.
+func RecordUsage(record *core.UsageRecord) (int, bool, error) {
+	record.Network = network
+
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return 0, false, err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("RecordUsage Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`INSERT IGNORE INTO %s (order_id, account, hour, cpu, ram, storage, cost, network)
+			VALUES (:order_id, :account, :hour, :cpu, :ram, :storage, :cost, :network);`, usageRecordsTable)
+	res, err := tx.NamedExec(query, record)
+	if err != nil {
+		return 0, false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+
+	billed := rows > 0
+	if billed {
+		err = creditBalance(tx, record.Account, -int64(record.Cost))
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	var balance int
+	query = fmt.Sprintf(`SELECT balance FROM %s WHERE account = ? AND network = ? `, balancesTable)
+	err = tx.QueryRow(query, record.Account, network).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	return balance, billed, tx.Commit()
+}
+
+// LoadOrderUsageRecords retrieves the usage records of an order, with pagination.
+func LoadOrderUsageRecords(ctx context.Context, orderID string, page, size int) ([]*core.UsageRecord, int64, error) {
+	out := make([]*core.UsageRecord, 0)
+
+	var count int64
+	if page < 1 {
+		page = 1
+	}
+
+	query, args, err := squirrel.Select("*").From(usageRecordsTable).Where(squirrel.Eq{"order_id": orderID}).OrderBy("hour DESC").Offset(uint64((page - 1) * size)).Limit(uint64(size)).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := mDB.SelectContext(ctx, &out, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query2, args2, err := squirrel.Select("COUNT(*)").From(usageRecordsTable).Where(squirrel.Eq{"order_id": orderID}).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = mDB.Get(&count, query2, args2...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return out, count, nil
+}
+
+// CreditDepositOrder marks a paid deposit order as done and adds its amount to the account balance in one transaction.
+func CreditDepositOrder(order *core.Order) error {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("CreditDepositOrder Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`UPDATE %s SET status=? WHERE id=? AND status=? `, orderInfoTable)
+	res, err := tx.Exec(query, core.OrderStatusDone, order.ID, core.OrderStatusPaid)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("deposit order %s is not paid", order.ID)
+	}
+
+	err = creditBalance(tx, order.Account, int64(order.Price))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -3,6 +3,7 @@ package dao
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"titan-container-platform/config"
@@ -29,8 +30,25 @@ const (
 	orderInfoTable    = "orders"
 	userClaimsTable   = "user_claims"
 	hourlyQuotasTable = "hourly_quotas"
+	usageRecordsTable = "usage_records"
+	balancesTable     = "account_balances"
+	chainSyncTable    = "chain_sync"
+	chainTxsTable     = "chain_txs"
+	settlementsTable  = "settlements"
+	depositsTable     = "deposit_payments"
+	chainOrdersTable  = "chain_orders"
+	faucetClaimsTable = "faucet_claims"
 )
 
+// network is the name of the network this instance runs on. Orders, broadcast txs and
+// mirrored orders are recorded under it, and the workers only load the ones of it.
+var network string
+
+// Network returns the name of the network this instance runs on.
+func Network() string {
+	return network
+}
+
 // ErrNoRow is returned when no matching row is found in the database.
 var ErrNoRow = fmt.Errorf("no matching row found")
 
@@ -51,16 +69,15 @@ func Init(cfg *config.Config) error {
 	db.SetConnMaxIdleTime(connMaxIdleTime * time.Second)
 
 	mDB = db
+	network = cfg.ChainAPI.Network
 
 	initTables()
 
 	return nil
 }
 
-// initTables initializes data tables.
+// initTables initializes data tables, then upgrades the ones created by older versions.
 func initTables() error {
-	doExec()
-
 	// init table
 	tx, err := mDB.Beginx()
 	if err != nil {
@@ -79,21 +96,201 @@ func initTables() error {
 	tx.MustExec(fmt.Sprintf(cOrderTable, orderInfoTable))
 	tx.MustExec(fmt.Sprintf(cUserClaimsTable, userClaimsTable))
 	tx.MustExec(fmt.Sprintf(cHourlyQuotasTable, hourlyQuotasTable))
+	tx.MustExec(fmt.Sprintf(cUsageRecordsTable, usageRecordsTable))
+	tx.MustExec(fmt.Sprintf(cAccountBalancesTable, balancesTable))
+	tx.MustExec(fmt.Sprintf(cChainSyncTable, chainSyncTable))
+	tx.MustExec(fmt.Sprintf(cChainTxsTable, chainTxsTable))
+	tx.MustExec(fmt.Sprintf(cSettlementsTable, settlementsTable))
+	tx.MustExec(fmt.Sprintf(cDepositPaymentsTable, depositsTable))
+	tx.MustExec(fmt.Sprintf(cChainOrdersTable, chainOrdersTable))
+	tx.MustExec(fmt.Sprintf(cFaucetClaimsTable, faucetClaimsTable))
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-	return tx.Commit()
+	doExec()
+
+	return nil
 }
 
+// doExec brings the tables created by older versions up to the creation statements.
+// Every step checks the schema first, so it runs once and is skipped on new tables.
 func doExec() {
-	// _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s CHANGE area_id area_id       VARCHAR(256)   DEFAULT ''", onlineCountTable))
-	// if err != nil {
-	// 	log.Errorf("InitTables doExec err:%s", err.Error())
-	// }
-	// _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN cpu_cores ;", projectInfoTable))
-	// if err != nil {
-	// 	log.Errorf("InitTables doExec err:%s", err.Error())
-	// }
-	// _, err := mDB.Exec(fmt.Sprintf("ALTER TABLE %s ADD price        INT           DEFAULT 0", orderInfoTable))
-	// if err != nil {
-	// 	log.Errorf("InitTables doExec err:%s", err.Error())
-	// }
+	addColumn(orderInfoTable, "surge_factor", "INT DEFAULT 100")
+	addColumn(orderInfoTable, "dimensions", "TEXT")
+	addColumn(orderInfoTable, "plan", "VARCHAR(32) DEFAULT ''")
+	addColumn(orderInfoTable, "periods", "INT DEFAULT 0")
+	addColumn(orderInfoTable, "paid_periods", "INT DEFAULT 0")
+	addColumn(orderInfoTable, "period_end", "DATETIME DEFAULT CURRENT_TIMESTAMP")
+	addIndex(orderInfoTable, "idx_plan", "plan")
+	addColumn(orderInfoTable, "mode", "INT DEFAULT 0")
+	addColumn(orderInfoTable, "tx_hash", "VARCHAR(128) DEFAULT ''")
+	addColumn(orderInfoTable, "renew_hash", "VARCHAR(128) DEFAULT ''")
+	addColumn(orderInfoTable, "refund_hash", "VARCHAR(128) DEFAULT ''")
+	addColumn(orderInfoTable, "blocks", "BIGINT DEFAULT 0")
+	addNetworkColumn(orderInfoTable)
+	addIndex(orderInfoTable, "idx_network", "network")
+	addNetworkColumn(chainTxsTable)
+	addNetworkColumn(chainOrdersTable)
+	addIndex(chainOrdersTable, "idx_network", "network")
+	// balances are kept apart for each network
+	addNetworkColumn(balancesTable)
+	dropIndex(balancesTable, "account")
+	setPrimaryKey(balancesTable, "account", "network")
+	addNetworkColumn(usageRecordsTable)
+	addNetworkColumn(settlementsTable)
+	addNetworkColumn(depositsTable)
+	// a tx is recorded once for each account it concerns
+	dropIndex(chainTxsTable, "hash")
+	setPrimaryKey(chainTxsTable, "hash", "account")
+	modifyColumn(userClaimsTable, "amount", "bigint", "BIGINT DEFAULT 0")
+	modifyColumn(hourlyQuotasTable, "amount", "bigint", "BIGINT DEFAULT 0")
+	addColumn(chainTxsTable, "claim_id", "BIGINT DEFAULT 0")
+	addIndex(chainTxsTable, "idx_claim_id", "claim_id")
+	// the faucet budget and claims are kept apart for each network
+	addNetworkColumn(userClaimsTable)
+	dropIndex(userClaimsTable, "account")
+	setPrimaryKey(userClaimsTable, "account", "network")
+	addNetworkColumn(hourlyQuotasTable)
+	setPrimaryKey(hourlyQuotasTable, "hour", "network")
+	addNetworkColumn(faucetClaimsTable)
+	// the amounts of the older settlements stay as they were recorded
+	if addColumn(settlementsTable, "confirmed", "TINYINT(1) DEFAULT 0") {
+		_, err := mDB.Exec(fmt.Sprintf("UPDATE %s SET confirmed = 1", settlementsTable))
+		if err != nil {
+			log.Errorf("InitTables doExec err:%s", err.Error())
+		}
+	}
+}
+
+// addColumn adds the column to the table unless it has it, and reports whether it did.
+func addColumn(table, column, definition string) bool {
+	var count int
+	query := `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	err := mDB.Get(&count, query, table, column)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return false
+	}
+
+	if count > 0 {
+		return false
+	}
+
+	_, err = mDB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return false
+	}
+
+	log.Infof("added column %s.%s", table, column)
+	return true
+}
+
+// modifyColumn changes the column of the table to the definition unless its data type already is dataType.
+func modifyColumn(table, column, dataType, definition string) {
+	var current string
+	query := `SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+	err := mDB.Get(&current, query, table, column)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	if strings.EqualFold(current, dataType) {
+		return
+	}
+
+	_, err = mDB.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	log.Infof("changed column %s.%s from %s to %s", table, column, current, dataType)
+}
+
+// addNetworkColumn adds the network column to the table. The rows already in it were written
+// by a version serving a single network, so they are put under the network of the instance.
+func addNetworkColumn(table string) {
+	if !addColumn(table, "network", "VARCHAR(32) DEFAULT ''") {
+		return
+	}
+
+	_, err := mDB.Exec(fmt.Sprintf("UPDATE %s SET network = ? WHERE network = ''", table), network)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+	}
+}
+
+// addIndex adds the index on the columns to the table unless it has an index with the name.
+func addIndex(table, name, columns string) {
+	var count int
+	query := `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+	err := mDB.Get(&count, query, table, name)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	if count > 0 {
+		return
+	}
+
+	_, err = mDB.Exec(fmt.Sprintf("ALTER TABLE %s ADD INDEX %s (%s)", table, name, columns))
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	log.Infof("added index %s.%s", table, name)
+}
+
+// dropIndex drops the index with the name from the table if it has it.
+func dropIndex(table, name string) {
+	var count int
+	query := `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+	err := mDB.Get(&count, query, table, name)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	if count == 0 {
+		return
+	}
+
+	_, err = mDB.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, name))
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	log.Infof("dropped index %s.%s", table, name)
+}
+
+// setPrimaryKey makes the columns the primary key of the table unless they already are.
+func setPrimaryKey(table string, columns ...string) {
+	var current []string
+	query := `SELECT COLUMN_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = 'PRIMARY' ORDER BY SEQ_IN_INDEX`
+	err := mDB.Select(&current, query, table)
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	key := strings.Join(columns, ", ")
+	if strings.Join(current, ", ") == key {
+		return
+	}
+
+	_, err = mDB.Exec(fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY, ADD PRIMARY KEY (%s)", table, key))
+	if err != nil {
+		log.Errorf("InitTables doExec err:%s", err.Error())
+		return
+	}
+
+	log.Infof("changed primary key of %s to (%s)", table, key)
 }
@@ -0,0 +1,194 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"titan-container-platform/core"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// syncName qualifies the name of a chain follower with the network, each network is followed apart.
+func syncName(name string) string {
+	if network == "" {
+		return name
+	}
+
+	return network + ":" + name
+}
+
+// GetSyncHeight retrieves the last block height processed by the named chain follower, 0 if it never ran.
+func GetSyncHeight(name string) (int64, error) {
+	query := fmt.Sprintf(`SELECT height FROM %s WHERE name = ? `, chainSyncTable)
+
+	var height int64
+	err := mDB.QueryRow(query, syncName(name)).Scan(&height)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return height, err
+}
+
+// SetSyncHeight saves the last block height processed by the named chain follower.
+func SetSyncHeight(name string, height int64) error {
+	query := fmt.Sprintf(`INSERT INTO %s (name, height) VALUES (?, ?) ON DUPLICATE KEY UPDATE height = ? `, chainSyncTable)
+	_, err := mDB.Exec(query, syncName(name), height, height)
+	return err
+}
+
+// CreateChainTxs saves the records of a tx broadcast on the network of the instance in one transaction.
+// A renewal tx is linked to its order and a faucet tx to its pending claim in the same transaction, so
+// they know the tx before it is broadcast: the order is not renewed again while it is pending, and a
+// claim without a tx was never paid.
+func CreateChainTxs(list []*core.ChainTx) error {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("CreateChainTxs Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`INSERT INTO %s (hash, purpose, account, order_id, status, code, gas_used, height, log, network, claim_id)
+			VALUES (:hash, :purpose, :account, :order_id, :status, :code, :gas_used, :height, :log, :network, :claim_id);`, chainTxsTable)
+	for _, info := range list {
+		info.Network = network
+		_, err = tx.NamedExec(query, info)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Purpose == core.TxPurposeOrderRenewal:
+			_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET renew_hash=? WHERE id=? `, orderInfoTable), info.Hash, info.OrderID)
+		case info.Purpose == core.TxPurposeFaucet && info.ClaimID > 0:
+			_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET tx_hash=? WHERE id=? AND status=? `, faucetClaimsTable), info.Hash, info.ClaimID, core.FaucetClaimPending)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadChainTxsByStatus retrieves the txs broadcast on the network based on their status.
+func LoadChainTxsByStatus(status core.TxStatus) ([]*core.ChainTx, error) {
+	var infos []*core.ChainTx
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE status=? AND network=?", chainTxsTable)
+	err := mDB.Select(&infos, query, status, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// UpdateChainTxResult saves the result of a broadcast tx.
+func UpdateChainTxResult(tx *core.ChainTx) error {
+	query := fmt.Sprintf(`UPDATE %s SET status=:status, code=:code, gas_used=:gas_used, height=:height, log=:log WHERE hash=:hash`, chainTxsTable)
+	_, err := mDB.NamedExec(query, tx)
+
+	return err
+}
+
+// GetChainTx retrieves a broadcast tx by its hash.
+func GetChainTx(hash string) (*core.ChainTx, error) {
+	var info core.ChainTx
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE hash=?", chainTxsTable)
+	err := mDB.Get(&info, query, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// LoadAccountChainTxs retrieves the faucet, payment, renewal and refund txs of an account on the network, newest first, with pagination.
+func LoadAccountChainTxs(ctx context.Context, account string, page, size int) ([]*core.ChainTx, int64, error) {
+	out := make([]*core.ChainTx, 0)
+
+	var count int64
+	if page < 1 {
+		page = 1
+	}
+
+	// refunds are recorded under the order only
+	where := squirrel.And{
+		squirrel.Eq{"purpose": []core.TxPurpose{core.TxPurposeFaucet, core.TxPurposeOrderPayment, core.TxPurposeOrderRenewal, core.TxPurposeOrderCancel}},
+		squirrel.Eq{"network": network},
+		squirrel.Or{
+			squirrel.Eq{"account": account},
+			squirrel.Expr(fmt.Sprintf("order_id IN (SELECT id FROM %s WHERE account = ?)", orderInfoTable), account),
+		},
+	}
+
+	query, args, err := squirrel.Select("*").From(chainTxsTable).Where(where).OrderBy("created_at DESC").Offset(uint64((page - 1) * size)).Limit(uint64(size)).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := mDB.SelectContext(ctx, &out, query, args...); err != nil {
+		return nil, 0, err
+	}
+
+	query2, args2, err := squirrel.Select("COUNT(*)").From(chainTxsTable).Where(where).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = mDB.Get(&count, query2, args2...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return out, count, nil
+}
+
+// ChainStore is the chain.Store of the database, on the network of the instance.
+type ChainStore struct{}
+
+// CreateChainTxs saves the records of a tx before it is broadcast, all or none.
+func (ChainStore) CreateChainTxs(list []*core.ChainTx) error {
+	return CreateChainTxs(list)
+}
+
+// UpdateChainTxResult saves the result of a broadcast tx.
+func (ChainStore) UpdateChainTxResult(tx *core.ChainTx) error {
+	return UpdateChainTxResult(tx)
+}
+
+// LoadChainTxsByStatus retrieves the broadcast txs with the status.
+func (ChainStore) LoadChainTxsByStatus(status core.TxStatus) ([]*core.ChainTx, error) {
+	return LoadChainTxsByStatus(status)
+}
+
+// GetSyncHeight retrieves the last block height processed by the named follower.
+func (ChainStore) GetSyncHeight(name string) (int64, error) {
+	return GetSyncHeight(name)
+}
+
+// SetSyncHeight saves the last block height processed by the named follower.
+func (ChainStore) SetSyncHeight(name string, height int64) error {
+	return SetSyncHeight(name, height)
+}
+
+// SaveChainOrders inserts or updates the mirrored orders and marks them synced at the time.
+func (ChainStore) SaveChainOrders(list []*core.ChainOrder, syncedAt time.Time) error {
+	return SaveChainOrders(list, syncedAt)
+}
+
+// DeleteChainOrdersSyncedBefore removes the mirrored orders last synced before the time.
+func (ChainStore) DeleteChainOrdersSyncedBefore(t time.Time) (int64, error) {
+	return DeleteChainOrdersSyncedBefore(t)
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"titan-container-platform/core"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// SaveChainOrders inserts or updates the mirrored orders of the network in one transaction and marks them synced at the time.
+func SaveChainOrders(list []*core.ChainOrder, syncedAt time.Time) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("SaveChainOrders Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, initiator, cpu, memory, disk, duration, locked_funds, start_height, end_height, status, network, synced_at)
+			VALUES (:id, :initiator, :cpu, :memory, :disk, :duration, :locked_funds, :start_height, :end_height, :status, :network, :synced_at)
+			ON DUPLICATE KEY UPDATE initiator=VALUES(initiator), cpu=VALUES(cpu), memory=VALUES(memory), disk=VALUES(disk),
+			duration=VALUES(duration), locked_funds=VALUES(locked_funds), start_height=VALUES(start_height),
+			end_height=VALUES(end_height), status=VALUES(status), synced_at=VALUES(synced_at);`, chainOrdersTable)
+	for _, o := range list {
+		o.Network = network
+		o.SyncedAt = syncedAt
+		_, err = tx.NamedExec(query, o)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteChainOrdersSyncedBefore removes the mirrored orders of the network that a full sync started at the time did not see.
+func DeleteChainOrdersSyncedBefore(t time.Time) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE synced_at < ? AND network = ? `, chainOrdersTable)
+	res, err := mDB.Exec(query, t, network)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// LoadChainOrdersByIDs retrieves the mirrored orders of the network with the ids.
+func LoadChainOrdersByIDs(ids []string) ([]*core.ChainOrder, error) {
+	out := make([]*core.ChainOrder, 0)
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	query, args, err := squirrel.Select("*").From(chainOrdersTable).Where(squirrel.Eq{"id": ids, "network": network}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = mDB.Select(&out, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// LoadChainOrders retrieves a page of the mirrored orders of the network matching the filter, by id after startAfter.
+func LoadChainOrders(ctx context.Context, filter *core.ChainOrderFilter, startAfter string, limit int) ([]*core.ChainOrder, error) {
+	out := make([]*core.ChainOrder, 0)
+
+	builder := squirrel.Select("*").From(chainOrdersTable).Where(squirrel.Gt{"id": startAfter}).Where(squirrel.Eq{"network": network})
+	if filter.Initiator != "" {
+		builder = builder.Where(squirrel.Eq{"initiator": filter.Initiator})
+	}
+	if filter.Status != "" {
+		builder = builder.Where(squirrel.Eq{"status": filter.Status})
+	}
+	if filter.FromHeight > 0 {
+		builder = builder.Where(squirrel.GtOrEq{"end_height": filter.FromHeight})
+	}
+	if filter.ToHeight > 0 {
+		builder = builder.Where(squirrel.LtOrEq{"start_height": filter.ToHeight})
+	}
+
+	query, args, err := builder.OrderBy("id").Limit(uint64(limit)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mDB.SelectContext(ctx, &out, query, args...); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
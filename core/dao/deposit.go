@@ -0,0 +1,241 @@
+package dao
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"titan-container-platform/core"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrStatusChanged is returned when an order is not in the status it is moved from.
+var ErrStatusChanged = errors.New("order status changed")
+
+// RecordDepositPayment saves the deposit payment, and adds its amount to the balance of
+// payment.Credited when it is set, in one transaction. It returns false when the
+// transfer was already recorded.
+func RecordDepositPayment(payment *core.DepositPayment) (bool, error) {
+	payment.Network = network
+
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("RecordDepositPayment Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`INSERT IGNORE INTO %s (tx_hash, idx, order_id, sender, amount, height, credited, network)
+			VALUES (:tx_hash, :idx, :order_id, :sender, :amount, :height, :credited, :network);`, depositsTable)
+	res, err := tx.NamedExec(query, payment)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	if payment.Credited != "" {
+		err = creditBalance(tx, payment.Credited, payment.Amount)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// SumOrderDepositPayments returns the amount transferred to the deposit address for the order
+// that was not credited to a balance yet.
+func SumOrderDepositPayments(orderID string) (int64, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(amount), 0) FROM %s WHERE order_id = ? AND credited = ''`, depositsTable)
+
+	var amount int64
+	err := mDB.Get(&amount, query, orderID)
+	return amount, err
+}
+
+// PayOrderByDeposit marks a created order as paid and adds the excess of its deposit
+// payments to the balance of the order account in one transaction. When the order
+// left the created status meanwhile, its uncredited deposit payments are added to the
+// balance of the order account instead, and ErrStatusChanged is returned.
+func PayOrderByDeposit(order *core.Order, excess int64) error {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("PayOrderByDeposit Rollback err:%s", err.Error())
+		}
+	}()
+
+	err = moveOrderStatus(tx, order.ID, core.OrderStatusCreated, core.OrderStatusPaid)
+	if errors.Is(err, ErrStatusChanged) {
+		amount, cerr := takeOrderDeposits(tx, order)
+		if cerr != nil {
+			return cerr
+		}
+
+		if amount > 0 {
+			if cerr = creditBalance(tx, order.Account, amount); cerr != nil {
+				return cerr
+			}
+		}
+
+		if cerr = tx.Commit(); cerr != nil {
+			return cerr
+		}
+
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if excess > 0 {
+		err = creditBalance(tx, order.Account, excess)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PayOrder marks a created order paid on the order contract as paid, and adds the deposit
+// payments it no longer needs to the balance of the order account in one transaction.
+// It returns the amount credited.
+func PayOrder(order *core.Order) (int64, error) {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("PayOrder Rollback err:%s", err.Error())
+		}
+	}()
+
+	err = moveOrderStatus(tx, order.ID, core.OrderStatusCreated, core.OrderStatusPaid)
+	if err != nil {
+		return 0, err
+	}
+
+	amount, err := takeOrderDeposits(tx, order)
+	if err != nil {
+		return 0, err
+	}
+
+	if amount > 0 {
+		err = creditBalance(tx, order.Account, amount)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return amount, tx.Commit()
+}
+
+// CreditOrderDeposits moves the order from one status to another and adds the amount
+// of its uncredited deposit payments to the balance of the order account in one transaction.
+// It is used to give back the deposits of orders that time out or fail, the excess
+// over the price was already credited when the order was paid.
+func CreditOrderDeposits(order *core.Order, from, to core.OrderStatus) error {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("CreditOrderDeposits Rollback err:%s", err.Error())
+		}
+	}()
+
+	err = moveOrderStatus(tx, order.ID, from, to)
+	if err != nil {
+		return err
+	}
+
+	amount, err := takeOrderDeposits(tx, order)
+	if err != nil {
+		return err
+	}
+
+	if amount > int64(order.Price) {
+		amount = int64(order.Price)
+	}
+
+	if amount > 0 {
+		err = creditBalance(tx, order.Account, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func moveOrderStatus(tx *sqlx.Tx, id string, from, to core.OrderStatus) error {
+	query := fmt.Sprintf(`UPDATE %s SET status=? WHERE id=? AND status=? `, orderInfoTable)
+	res, err := tx.Exec(query, to, id, from)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: order %s is not in status %d", ErrStatusChanged, id, from)
+	}
+
+	return nil
+}
+
+// takeOrderDeposits marks the uncredited deposit payments of the order as credited to the
+// order account and returns their amount. The caller adds it to a balance in the same tx.
+func takeOrderDeposits(tx *sqlx.Tx, order *core.Order) (int64, error) {
+	var amount int64
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(amount), 0) FROM %s WHERE order_id = ? AND credited = '' FOR UPDATE`, depositsTable)
+	err := tx.Get(&amount, query, order.ID)
+	if err != nil || amount == 0 {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET credited = ? WHERE order_id = ? AND credited = ''`, depositsTable)
+	_, err = tx.Exec(query, order.Account, order.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+// creditBalance adds the amount, which is negative for a debit, to the balance of the account on the network.
+func creditBalance(tx *sqlx.Tx, account string, amount int64) error {
+	query := fmt.Sprintf(`INSERT INTO %s (account, network, balance) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE balance = balance + ? `, balancesTable)
+	_, err := tx.Exec(query, account, network, amount, amount)
+
+	return err
+}
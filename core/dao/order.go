@@ -3,27 +3,30 @@ package dao
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"titan-container-platform/core"
 
 	"github.com/Masterminds/squirrel"
 )
 
-// CreateOrder creates a new order in the database.
+// CreateOrder creates a new order in the database, on the network of the instance.
 func CreateOrder(ctx context.Context, order *core.Order) error {
-	query := fmt.Sprintf(`INSERT INTO %s (id, account, cpu, ram, storage, duration, status, price)
-			VALUES (:id, :account, :cpu, :ram, :storage, :duration, :status, :price);`, orderInfoTable)
+	order.Network = network
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, account, cpu, ram, storage, duration, status, price, surge_factor, dimensions, plan, periods, mode, network)
+			VALUES (:id, :account, :cpu, :ram, :storage, :duration, :status, :price, :surge_factor, :dimensions, :plan, :periods, :mode, :network);`, orderInfoTable)
 	_, err := mDB.NamedExec(query, order)
 
 	return err
 }
 
-// LoadOrdersByStatus retrieves orders based on their status.
+// LoadOrdersByStatus retrieves the orders of the network based on their status.
 func LoadOrdersByStatus(status core.OrderStatus) ([]*core.Order, error) {
 	var infos []*core.Order
 
-	query := fmt.Sprintf("SELECT * FROM %s WHERE status=?", orderInfoTable)
-	err := mDB.Select(&infos, query, status)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE status=? AND network=?", orderInfoTable)
+	err := mDB.Select(&infos, query, status, network)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +54,165 @@ func UpdateOrderStatus(id string, status core.OrderStatus) error {
 	return err
 }
 
+// MoveOrderStatus moves an order from one status to another, and returns ErrStatusChanged
+// if the order is no longer in the status it is moved from.
+func MoveOrderStatus(id string, from, to core.OrderStatus) error {
+	query := fmt.Sprintf(`UPDATE %s SET status=? WHERE id=? AND status=? `, orderInfoTable)
+	res, err := mDB.Exec(query, to, id, from)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: order %s is not in status %d", ErrStatusChanged, id, from)
+	}
+
+	return nil
+}
+
+// LoadOrdersByModeAndStatus retrieves the orders of the network based on their billing mode and status.
+func LoadOrdersByModeAndStatus(mode core.OrderMode, status core.OrderStatus) ([]*core.Order, error) {
+	var infos []*core.Order
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE mode=? AND status=? AND network=?", orderInfoTable)
+	err := mDB.Select(&infos, query, mode, status, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// LoadAccountOrdersByModeAndStatus retrieves the orders of an account based on their billing mode and status.
+func LoadAccountOrdersByModeAndStatus(account string, mode core.OrderMode, status core.OrderStatus) ([]*core.Order, error) {
+	var infos []*core.Order
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE account=? AND mode=? AND status=?", orderInfoTable)
+	err := mDB.Select(&infos, query, account, mode, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// GetOrder retrieves an order by its id.
+func GetOrder(ctx context.Context, id string) (*core.Order, error) {
+	var out core.Order
+	if err := mDB.QueryRowxContext(ctx, fmt.Sprintf(
+		`SELECT * FROM %s WHERE id = ?`, orderInfoTable), id,
+	).StructScan(&out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// LoadPlanOrdersByStatus retrieves the commitment plan orders of the network based on their status.
+func LoadPlanOrdersByStatus(status core.OrderStatus) ([]*core.Order, error) {
+	var infos []*core.Order
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE status=? AND plan<>'' AND network=?", orderInfoTable)
+	err := mDB.Select(&infos, query, status, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// UpdateOrderTxHash links the payment tx to an order.
+func UpdateOrderTxHash(id, txHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET tx_hash=? WHERE id=? `, orderInfoTable)
+	_, err := mDB.Exec(query, txHash, id)
+
+	return err
+}
+
+// SetOrderBlocks sets the duration in blocks of an order unless it is already set,
+// and returns the duration the order ends up with.
+func SetOrderBlocks(id string, blocks uint64) (uint64, error) {
+	query := fmt.Sprintf(`UPDATE %s SET blocks=? WHERE id=? AND blocks=0 `, orderInfoTable)
+	_, err := mDB.Exec(query, blocks, id)
+	if err != nil {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`SELECT blocks FROM %s WHERE id=? `, orderInfoTable)
+	err = mDB.Get(&blocks, query, id)
+
+	return blocks, err
+}
+
+// UpdateOrderRefundHash links the refund tx to an order, an empty hash clears a failed refund.
+func UpdateOrderRefundHash(id, txHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET refund_hash=? WHERE id=? `, orderInfoTable)
+	_, err := mDB.Exec(query, txHash, id)
+
+	return err
+}
+
+// LoadRefundableOrders retrieves the orders of the network with the status that still have a refund
+// to send or to confirm: a refund tx in flight, funds locked on the order contract or uncredited deposits.
+func LoadRefundableOrders(status core.OrderStatus) ([]*core.Order, error) {
+	var infos []*core.Order
+
+	query := fmt.Sprintf(`SELECT o.* FROM %s o WHERE o.status=? AND o.network=? AND (o.refund_hash != ''
+			OR EXISTS (SELECT 1 FROM %s c WHERE c.id = o.id AND c.locked_funds > 0)
+			OR EXISTS (SELECT 1 FROM %s d WHERE d.order_id = o.id AND d.credited = ''))`,
+		orderInfoTable, chainOrdersTable, depositsTable)
+	err := mDB.Select(&infos, query, status, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// UpdateOrderPeriod updates the paid periods and the current period end of a commitment plan order.
+func UpdateOrderPeriod(id string, paidPeriods int, periodEnd time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET paid_periods=?, period_end=? WHERE id=? `, orderInfoTable)
+	_, err := mDB.Exec(query, paidPeriods, periodEnd, id)
+
+	return err
+}
+
+// UpdateOrderRenewHash links the renewal tx of the next period to a commitment plan order,
+// an empty hash clears a failed renewal.
+func UpdateOrderRenewHash(id, txHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET renew_hash=? WHERE id=? `, orderInfoTable)
+	_, err := mDB.Exec(query, txHash, id)
+
+	return err
+}
+
+// FinishOrderRenewal credits the period paid by the confirmed renewal tx of a commitment
+// plan order and clears the tx, unless the order moved on to another renewal.
+func FinishOrderRenewal(id, txHash string, paidPeriods int, periodEnd time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET paid_periods=?, period_end=?, renew_hash='' WHERE id=? AND renew_hash=? `, orderInfoTable)
+	_, err := mDB.Exec(query, paidPeriods, periodEnd, id, txHash)
+
+	return err
+}
+
+// SumActiveOrderResources returns the cpu cores and ram allocated by paid and running orders.
+func SumActiveOrderResources() (int, int, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(cpu), 0), COALESCE(SUM(ram), 0) FROM %s WHERE status IN (?, ?)`, orderInfoTable)
+
+	var cpu, ram int
+	err := mDB.QueryRow(query, core.OrderStatusPaid, core.OrderStatusDone).Scan(&cpu, &ram)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return cpu, ram, nil
+}
+
 // LoadAccountOrdersByStatus retrieves a list of orders for a given account, with pagination.
 func LoadAccountOrdersByStatus(ctx context.Context, account string, status core.OrderStatus, page, size int) ([]*core.Order, int64, error) {
 	out := make([]*core.Order, 0)
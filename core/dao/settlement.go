@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"fmt"
+
+	"titan-container-platform/core"
+)
+
+// settledFilter leaves out the settlements whose tx failed on chain.
+var settledFilter = fmt.Sprintf(`LEFT JOIN %s t ON t.hash = s.tx_hash WHERE (t.status IS NULL OR t.status != %d)`, chainTxsTable, core.TxStatusFailed)
+
+// CreateSettlement saves a settlement on the network of the instance, with the estimated amount until it is confirmed.
+func CreateSettlement(settlement *core.Settlement) error {
+	settlement.Network = network
+
+	query := fmt.Sprintf(`INSERT INTO %s (order_id, account, amount, height, tx_hash, network)
+			VALUES (:order_id, :account, :amount, :height, :tx_hash, :network);`, settlementsTable)
+	_, err := mDB.NamedExec(query, settlement)
+
+	return err
+}
+
+// SumOrderSettlements returns the amount settled for the order, leaving out the failed settlements.
+func SumOrderSettlements(orderID string) (int64, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(s.amount), 0) FROM %s s %s AND s.order_id = ?`, settlementsTable, settledFilter)
+
+	var amount int64
+	err := mDB.Get(&amount, query, orderID)
+	return amount, err
+}
+
+// ConfirmSettlement saves the amount released by the confirmed tx of the settlement.
+func ConfirmSettlement(id, amount int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET amount=?, confirmed=1 WHERE id=?`, settlementsTable)
+	_, err := mDB.Exec(query, amount, id)
+
+	return err
+}
+
+// LoadUnconfirmedSettlements retrieves the settlements of the network whose tx is confirmed
+// but whose released amount is not saved yet.
+func LoadUnconfirmedSettlements() ([]*core.Settlement, error) {
+	var infos []*core.Settlement
+
+	query := fmt.Sprintf(`SELECT s.* FROM %s s JOIN %s t ON t.hash = s.tx_hash WHERE s.confirmed = 0 AND t.status = ? AND s.network = ?`,
+		settlementsTable, chainTxsTable)
+	err := mDB.Select(&infos, query, core.TxStatusConfirmed, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// SettlementTotals returns the amount released by the confirmed settlements on the network, the number
+// of those settlements and the number of settled orders.
+func SettlementTotals() (int64, int64, int64, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(s.amount), 0), COUNT(s.id), COUNT(DISTINCT s.order_id) FROM %s s %s AND s.confirmed = 1 AND s.network = ?`, settlementsTable, settledFilter)
+
+	var amount, count, orders int64
+	err := mDB.QueryRow(query, network).Scan(&amount, &count, &orders)
+	return amount, count, orders, err
+}
+
+// LoadUnsettledOrders retrieves the orders of the mode and status whose settled amount is below their paid funds.
+func LoadUnsettledOrders(mode core.OrderMode, status core.OrderStatus) ([]*core.Order, error) {
+	var infos []*core.Order
+
+	query := fmt.Sprintf(`SELECT o.* FROM %s o WHERE o.mode=? AND o.status=? AND o.network=?
+			AND (SELECT COALESCE(SUM(s.amount), 0) FROM %s s %s AND s.order_id = o.id) < o.price * GREATEST(o.paid_periods, 1)`,
+		orderInfoTable, settlementsTable, settledFilter)
+	err := mDB.Select(&infos, query, mode, status, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
@@ -19,25 +19,167 @@ var cOrderTable = `
 		ram          INT           DEFAULT 0,
 		storage      INT           DEFAULT 0,
 		duration     INT           DEFAULT 0,
+		blocks       BIGINT        DEFAULT 0,
 		status       INT           DEFAULT 0,
 		price        INT           DEFAULT 0,
+		surge_factor INT           DEFAULT 100,
+		dimensions   TEXT,
+		plan         VARCHAR(32)   DEFAULT '',
+		periods      INT           DEFAULT 0,
+		paid_periods INT           DEFAULT 0,
+		period_end   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		mode         INT           DEFAULT 0,
+		tx_hash      VARCHAR(128)  DEFAULT '',
+		refund_hash  VARCHAR(128)  DEFAULT '',
+		renew_hash   VARCHAR(128)  DEFAULT '',
+		network      VARCHAR(32)   DEFAULT '',
 		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
 		PRIMARY KEY (id),
 		KEY idx_account (account),
-		KEY idx_status (status)
+		KEY idx_status (status),
+		KEY idx_plan (plan),
+		KEY idx_network (network)
 	) ENGINE=InnoDB COMMENT='order info';`
 
 var cUserClaimsTable = `
     CREATE TABLE if not exists %s (
-		account             VARCHAR(128)  NOT NULL UNIQUE,
-		amount              INT           DEFAULT 0,
+		account             VARCHAR(128)  NOT NULL,
+		network             VARCHAR(32)   DEFAULT '',
+		amount              BIGINT        DEFAULT 0,
 		last_claim          DATETIME      DEFAULT CURRENT_TIMESTAMP,
-		PRIMARY KEY (account)
+		PRIMARY KEY (account, network)
 	) ENGINE=InnoDB COMMENT='user claims info';`
 
 var cHourlyQuotasTable = `
     CREATE TABLE if not exists %s (
-		hour      TIMESTAMP  NOT NULL ,
-		amount    INT        DEFAULT 0,
-		PRIMARY KEY (hour)
-	) ENGINE=InnoDB COMMENT='user claims info';`
+		hour      TIMESTAMP    NOT NULL ,
+		network   VARCHAR(32)  DEFAULT '',
+		amount    BIGINT       DEFAULT 0,
+		PRIMARY KEY (hour, network)
+	) ENGINE=InnoDB COMMENT='faucet amounts given out per budget window';`
+
+var cUsageRecordsTable = `
+    CREATE TABLE if not exists %s (
+		order_id     VARCHAR(128)  NOT NULL,
+		account      VARCHAR(255)  NOT NULL,
+		hour         DATETIME      NOT NULL,
+		cpu          DOUBLE        DEFAULT 0,
+		ram          DOUBLE        DEFAULT 0,
+		storage      DOUBLE        DEFAULT 0,
+		cost         INT           DEFAULT 0,
+		network      VARCHAR(32)   DEFAULT '',
+		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (order_id, hour),
+		KEY idx_account (account)
+	) ENGINE=InnoDB COMMENT='hourly usage records of metered orders';`
+
+var cAccountBalancesTable = `
+    CREATE TABLE if not exists %s (
+		account      VARCHAR(255)  NOT NULL,
+		network      VARCHAR(32)   DEFAULT '',
+		balance      INT           DEFAULT 0,
+		updated_at   DATETIME      DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (account, network)
+	) ENGINE=InnoDB COMMENT='prepaid balances for metered orders';`
+
+var cChainSyncTable = `
+    CREATE TABLE if not exists %s (
+		name         VARCHAR(64)   NOT NULL UNIQUE,
+		height       BIGINT        DEFAULT 0,
+		updated_at   DATETIME      DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (name)
+	) ENGINE=InnoDB COMMENT='last processed block heights';`
+
+var cChainTxsTable = `
+    CREATE TABLE if not exists %s (
+		hash         VARCHAR(128)  NOT NULL,
+		purpose      VARCHAR(32)   NOT NULL,
+		account      VARCHAR(255)  DEFAULT '',
+		order_id     VARCHAR(128)  DEFAULT '',
+		status       INT           DEFAULT 0,
+		code         INT UNSIGNED  DEFAULT 0,
+		gas_used     BIGINT        DEFAULT 0,
+		height       BIGINT        DEFAULT 0,
+		log          TEXT,
+		network      VARCHAR(32)   DEFAULT '',
+		claim_id     BIGINT        DEFAULT 0,
+		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		updated_at   DATETIME      DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (hash, account),
+		KEY idx_account (account),
+		KEY idx_order_id (order_id),
+		KEY idx_status (status),
+		KEY idx_claim_id (claim_id)
+	) ENGINE=InnoDB COMMENT='txs broadcast by the platform';`
+
+var cSettlementsTable = `
+    CREATE TABLE if not exists %s (
+		id           BIGINT        NOT NULL AUTO_INCREMENT,
+		order_id     VARCHAR(128)  NOT NULL,
+		account      VARCHAR(255)  NOT NULL,
+		amount       BIGINT        DEFAULT 0,
+		height       BIGINT        DEFAULT 0,
+		tx_hash      VARCHAR(128)  NOT NULL,
+		network      VARCHAR(32)   DEFAULT '',
+		confirmed    TINYINT(1)    DEFAULT 0,
+		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY idx_order_id (order_id),
+		KEY idx_tx_hash (tx_hash)
+	) ENGINE=InnoDB COMMENT='settlements of the earned funds of orders';`
+
+var cDepositPaymentsTable = `
+    CREATE TABLE if not exists %s (
+		id           BIGINT        NOT NULL AUTO_INCREMENT,
+		tx_hash      VARCHAR(128)  NOT NULL,
+		idx          INT           DEFAULT 0,
+		order_id     VARCHAR(128)  DEFAULT '',
+		sender       VARCHAR(255)  NOT NULL,
+		amount       BIGINT        DEFAULT 0,
+		height       BIGINT        DEFAULT 0,
+		credited     VARCHAR(255)  DEFAULT '',
+		network      VARCHAR(32)   DEFAULT '',
+		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		UNIQUE KEY uk_tx (tx_hash, idx),
+		KEY idx_order_id (order_id)
+	) ENGINE=InnoDB COMMENT='token transfers to the deposit address';`
+
+var cChainOrdersTable = `
+    CREATE TABLE if not exists %s (
+		id           VARCHAR(128)     NOT NULL UNIQUE,
+		initiator    VARCHAR(255)     NOT NULL,
+		cpu          INT UNSIGNED     DEFAULT 0,
+		memory       INT UNSIGNED     DEFAULT 0,
+		disk         INT UNSIGNED     DEFAULT 0,
+		duration     BIGINT UNSIGNED  DEFAULT 0,
+		locked_funds BIGINT UNSIGNED  DEFAULT 0,
+		start_height BIGINT UNSIGNED  DEFAULT 0,
+		end_height   BIGINT UNSIGNED  DEFAULT 0,
+		status       VARCHAR(32)      DEFAULT '',
+		network      VARCHAR(32)      DEFAULT '',
+		synced_at    DATETIME         DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY idx_initiator (initiator),
+		KEY idx_network (network),
+		KEY idx_status (status),
+		KEY idx_heights (start_height, end_height)
+	) ENGINE=InnoDB COMMENT='mirror of the orders of the order contract';`
+
+var cFaucetClaimsTable = `
+    CREATE TABLE if not exists %s (
+		id           BIGINT        NOT NULL AUTO_INCREMENT,
+		account      VARCHAR(128)  NOT NULL,
+		amount       BIGINT        DEFAULT 0,
+		window_start TIMESTAMP     NOT NULL,
+		claimed_at   DATETIME      NOT NULL,
+		prev_claim   DATETIME      NULL,
+		status       INT           DEFAULT 0,
+		tx_hash      VARCHAR(128)  DEFAULT '',
+		network      VARCHAR(32)   DEFAULT '',
+		created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+		updated_at   DATETIME      DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY idx_account (account),
+		KEY idx_status (status)
+	) ENGINE=InnoDB COMMENT='faucet claims and their payout';`
@@ -4,52 +4,207 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"titan-container-platform/core"
 )
 
-func GetOrCreateHourlyQuota(currentHour time.Time) (int, error) {
-	queryS := fmt.Sprintf(`SELECT distributed_amount FROM %s WHERE hour = ? `, hourlyQuotasTable)
-	queryI := fmt.Sprintf(`INSERT INTO %s (hour, distributed_amount) VALUES (?, 0) `, hourlyQuotasTable)
+// GetWindowClaimed retrieves the amount given out by the faucet of the network in the budget window starting at the time.
+func GetWindowClaimed(start time.Time) (int64, error) {
+	query := fmt.Sprintf(`SELECT amount FROM %s WHERE hour = ? AND network = ? `, hourlyQuotasTable)
 
-	var distributedAmount int
-	err := mDB.QueryRow(queryS, currentHour).Scan(&distributedAmount)
+	var amount int64
+	err := mDB.QueryRow(query, start, network).Scan(&amount)
 	if err == sql.ErrNoRows {
-		_, err = mDB.Exec(queryI, currentHour)
-		if err != nil {
-			return 0, err
-		}
 		return 0, nil
 	}
+
+	return amount, err
+}
+
+// GetAccountClaims retrieves the total amount an account claimed from the faucet of the network
+// and the time of its last claim, which is zero when it never claimed.
+func GetAccountClaims(account string) (int64, time.Time, error) {
+	query := fmt.Sprintf(`SELECT amount, last_claim FROM %s WHERE account = ? AND network = ? `, userClaimsTable)
+
+	var amount int64
+	var lastClaim sql.NullTime
+	err := mDB.QueryRow(query, account, network).Scan(&amount, &lastClaim)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, nil
+	}
+
+	return amount, lastClaim.Time, err
+}
+
+// ReserveFaucetClaim locks the faucet budget window and the claims of the account on the network, and
+// passes their amounts to check. When check returns no error code, the claim is added
+// to both and saved as pending, all in one transaction, so concurrent claims cannot
+// overdraw the budget. It returns the error code of check.
+func ReserveFaucetClaim(claim *core.FaucetClaim, check func(windowClaimed, claimed int64, lastClaim time.Time) int) (int, error) {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("ReserveFaucetClaim Rollback err:%s", err.Error())
+		}
+	}()
+
+	claim.Network = network
+
+	// the rows must exist to be locked, the window is always locked first
+	query := fmt.Sprintf(`INSERT IGNORE INTO %s (hour, network, amount) VALUES (?, ?, 0) `, hourlyQuotasTable)
+	_, err = tx.Exec(query, claim.WindowStart, claim.Network)
+	if err != nil {
+		return 0, err
+	}
+
+	var windowClaimed int64
+	query = fmt.Sprintf(`SELECT amount FROM %s WHERE hour = ? AND network = ? FOR UPDATE `, hourlyQuotasTable)
+	err = tx.QueryRow(query, claim.WindowStart, claim.Network).Scan(&windowClaimed)
+	if err != nil {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`INSERT IGNORE INTO %s (account, network, amount, last_claim) VALUES (?, ?, 0, NULL) `, userClaimsTable)
+	_, err = tx.Exec(query, claim.Account, claim.Network)
 	if err != nil {
 		return 0, err
 	}
-	return distributedAmount, nil
+
+	var claimed int64
+	query = fmt.Sprintf(`SELECT amount, last_claim FROM %s WHERE account = ? AND network = ? FOR UPDATE `, userClaimsTable)
+	err = tx.QueryRow(query, claim.Account, claim.Network).Scan(&claimed, &claim.PrevClaim)
+	if err != nil {
+		return 0, err
+	}
+
+	if code := check(windowClaimed, claimed, claim.PrevClaim.Time); code != 0 {
+		return code, nil
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET amount = amount + ? WHERE hour = ? AND network = ? `, hourlyQuotasTable)
+	_, err = tx.Exec(query, claim.Amount, claim.WindowStart, claim.Network)
+	if err != nil {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET amount = amount + ?, last_claim = ? WHERE account = ? AND network = ? `, userClaimsTable)
+	_, err = tx.Exec(query, claim.Amount, claim.ClaimedAt, claim.Account, claim.Network)
+	if err != nil {
+		return 0, err
+	}
+
+	claim.Status = core.FaucetClaimPending
+	query = fmt.Sprintf(`INSERT INTO %s (account, amount, window_start, claimed_at, prev_claim, status, network)
+			VALUES (:account, :amount, :window_start, :claimed_at, :prev_claim, :status, :network);`, faucetClaimsTable)
+	res, err := tx.NamedExec(query, claim)
+	if err != nil {
+		return 0, err
+	}
+
+	claim.ID, err = res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, tx.Commit()
 }
 
-func UpdateHourlyQuota(amount int, currentHour time.Time) error {
-	query := fmt.Sprintf(`UPDATE %s SET distributed_amount = distributed_amount + ? WHERE hour = ? `, hourlyQuotasTable)
-	_, err := mDB.Exec(query, amount, currentHour)
+// FinishFaucetClaim marks a pending faucet claim as sent in the tx with the hash.
+func FinishFaucetClaim(id int64, txHash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET status=?, tx_hash=? WHERE id=? AND status=? `, faucetClaimsTable)
+	_, err := mDB.Exec(query, core.FaucetClaimSent, txHash, id, core.FaucetClaimPending)
+
 	return err
 }
 
-func GetLastClaimByAccount(account string) (time.Time, error) {
-	query := fmt.Sprintf(`SELECT %s FROM user_claims WHERE account = ? `, userClaimsTable)
-	var lastClaim time.Time
-	err := mDB.QueryRow(query, account).Scan(&lastClaim)
-	if err != nil && err != sql.ErrNoRows {
-		return lastClaim, err
+// RollbackFaucetClaim marks a pending or sent faucet claim as failed and takes its amount
+// off the budget window and the claims of the account on its network in one transaction. The last claim
+// time of the account is restored unless the account claimed again since.
+func RollbackFaucetClaim(claim *core.FaucetClaim) error {
+	tx, err := mDB.Beginx()
+	if err != nil {
+		return err
 	}
 
-	return lastClaim, err
+	defer func() {
+		err = tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			log.Errorf("RollbackFaucetClaim Rollback err:%s", err.Error())
+		}
+	}()
+
+	query := fmt.Sprintf(`UPDATE %s SET status=? WHERE id=? AND status IN (?, ?) `, faucetClaimsTable)
+	res, err := tx.Exec(query, core.FaucetClaimFailed, claim.ID, core.FaucetClaimPending, core.FaucetClaimSent)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		// already rolled back
+		return nil
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET amount = GREATEST(amount - ?, 0) WHERE hour = ? AND network = ? `, hourlyQuotasTable)
+	_, err = tx.Exec(query, claim.Amount, claim.WindowStart, claim.Network)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET amount = GREATEST(amount - ?, 0), last_claim = IF(last_claim = ?, ?, last_claim) WHERE account = ? AND network = ? `, userClaimsTable)
+	_, err = tx.Exec(query, claim.Amount, claim.ClaimedAt, claim.PrevClaim, claim.Account, claim.Network)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func SetAccountClaims(account string, maxUserQuota int) error {
-	query := fmt.Sprintf(`INSERT INTO %s (account, amount, last_claim) VALUES (?, ?, ?) `, userClaimsTable)
-	_, err := mDB.Exec(query, account, maxUserQuota, time.Now())
-	return err
+// LoadFaucetClaimsByStatus retrieves the faucet claims of the network with the status created before the time.
+func LoadFaucetClaimsByStatus(status core.FaucetClaimStatus, before time.Time) ([]*core.FaucetClaim, error) {
+	var infos []*core.FaucetClaim
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE status=? AND created_at<? AND network=?", faucetClaimsTable)
+	err := mDB.Select(&infos, query, status, before, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
 }
 
-func UpdateAccountClaims(account string, maxUserQuota int) error {
-	query := fmt.Sprintf(`UPDATE %s SET amount = amount + ?, last_claim = ? WHERE account = ? `, userClaimsTable)
-	_, err := mDB.Exec(query, maxUserQuota, time.Now(), account)
-	return err
+// LoadFailedSentFaucetClaims retrieves the sent faucet claims of the network whose tx failed on chain.
+func LoadFailedSentFaucetClaims() ([]*core.FaucetClaim, error) {
+	var infos []*core.FaucetClaim
+
+	query := fmt.Sprintf(`SELECT c.* FROM %s c JOIN %s t ON t.hash = c.tx_hash AND t.account = c.account
+			WHERE c.status=? AND t.status=? AND c.network=?`, faucetClaimsTable, chainTxsTable)
+	err := mDB.Select(&infos, query, core.FaucetClaimSent, core.TxStatusFailed, network)
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// GetFaucetClaim retrieves a faucet claim by its id.
+func GetFaucetClaim(id int64) (*core.FaucetClaim, error) {
+	var info core.FaucetClaim
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id=?", faucetClaimsTable)
+	err := mDB.Get(&info, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
 }
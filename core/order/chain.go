@@ -0,0 +1,88 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"titan-container-platform/chain"
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+// maxChainOrders caps a page of the on-chain orders.
+const maxChainOrders = 100
+
+// ChainOrder is an order of the order contract with its estimated wall-clock times.
+type ChainOrder struct {
+	*core.ChainOrder
+	StartTime *time.Time `json:"start_time,omitempty"` // nil when it cannot be estimated yet
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+// ChainOrders returns a page of the mirrored on-chain orders matching the filter, after
+// the order with the id startAfter, with their start and end times estimated from the block time.
+func ChainOrders(ctx context.Context, filter *core.ChainOrderFilter, startAfter string, limit int) ([]*ChainOrder, error) {
+	if limit <= 0 || limit > maxChainOrders {
+		limit = maxChainOrders
+	}
+
+	list, err := dao.LoadChainOrders(ctx, filter, startAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*ChainOrder, 0, len(list))
+	for _, o := range list {
+		co := &ChainOrder{ChainOrder: o}
+
+		if o.StartHeight > 0 {
+			if start, err := chainClient.HeightTime(int64(o.StartHeight)); err == nil {
+				co.StartTime = &start
+			}
+			if end, err := chainClient.HeightTime(int64(o.EndHeight)); err == nil {
+				co.EndTime = &end
+			}
+		}
+
+		out = append(out, co)
+	}
+
+	return out, nil
+}
+
+// BlockTime returns the average block time the order times are estimated with.
+func BlockTime() time.Duration {
+	return chainClient.BlockTime()
+}
+
+// serviceTx returns the recorded tx of the service with the hash. A tx without a record
+// is looked up on the chain, and taken as pending while the chain does not have it.
+func serviceTx(hash string) (*core.ChainTx, error) {
+	tx, err := dao.GetChainTx(hash)
+	if err != sql.ErrNoRows {
+		return tx, err
+	}
+
+	log.Warnf("tx %s has no record, looking it up on chain", hash)
+
+	tx = &core.ChainTx{Hash: hash, Status: core.TxStatusPending}
+
+	res, err := chainClient.GetTx(hash)
+	if err == chain.ErrTxNotFound {
+		return tx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Status = core.TxStatusConfirmed
+	if res.Code != 0 {
+		tx.Status = core.TxStatusFailed
+	}
+	tx.Code = res.Code
+	tx.Height = res.Height
+	tx.Log = res.Log
+
+	return tx, nil
+}
@@ -0,0 +1,119 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"titan-container-platform/chain"
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+// handleDepositTransfer pays the order in the memo of a transfer to the deposit address.
+// Transfers add up until they cover the order price, the excess goes to the balance
+// of the order account. A transfer that matches no created prepaid or deposit order
+// of this network goes to the balance of its sender.
+func handleDepositTransfer(e *chain.Event) {
+	amount, err := strconv.ParseInt(e.Amount, 10, 64)
+	if err != nil || amount <= 0 {
+		log.Warnf("deposit transfer %s has invalid amount %s", e.TxHash, e.Amount)
+		return
+	}
+
+	payment := &core.DepositPayment{
+		TxHash:  e.TxHash,
+		Idx:     e.Index,
+		OrderID: strings.TrimSpace(e.Memo),
+		Sender:  e.From,
+		Amount:  amount,
+		Height:  e.Height,
+	}
+
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	var info *core.Order
+	if payment.OrderID != "" {
+		info, err = dao.GetOrder(context.Background(), payment.OrderID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Errorf("GetOrder %s err:%s", payment.OrderID, err.Error())
+			return
+		}
+	}
+
+	if info == nil || info.Network != dao.Network() || info.Status != core.OrderStatusCreated || info.Mode == core.OrderModeMetered {
+		payment.Credited = payment.Sender
+
+		recorded, err := dao.RecordDepositPayment(payment)
+		if err != nil {
+			log.Errorf("RecordDepositPayment %s err:%s", payment.TxHash, err.Error())
+		} else if recorded {
+			log.Warnf("deposit transfer %s with memo %q matches no payable order, credited %d to %s", payment.TxHash, payment.OrderID, amount, payment.Sender)
+		}
+		return
+	}
+
+	recorded, err := dao.RecordDepositPayment(payment)
+	if err != nil {
+		log.Errorf("RecordDepositPayment %s err:%s", payment.TxHash, err.Error())
+		return
+	}
+
+	if !recorded {
+		return
+	}
+
+	paid, err := dao.SumOrderDepositPayments(info.ID)
+	if err != nil {
+		log.Errorf("SumOrderDepositPayments %s err:%s", info.ID, err.Error())
+		return
+	}
+
+	if paid < int64(info.Price) {
+		log.Infof("order %s is under-paid by deposit, %d of %d", info.ID, paid, info.Price)
+		return
+	}
+
+	excess := paid - int64(info.Price)
+	err = dao.PayOrderByDeposit(info, excess)
+	if errors.Is(err, dao.ErrStatusChanged) {
+		log.Warnf("order %s left the created status, credited its deposits to %s", info.ID, info.Account)
+		return
+	}
+	if err != nil {
+		log.Errorf("PayOrderByDeposit %s err:%s", info.ID, err.Error())
+		return
+	}
+
+	if excess > 0 {
+		log.Infof("order %s is over-paid by deposit, credited %d to %s", info.ID, excess, info.Account)
+	}
+
+	wakeProvisioner()
+}
+
+// creditDeposits moves the order to the status and gives back its deposit payments to
+// the balance of the order account. It returns false when the order has none.
+func creditDeposits(order *core.Order, to core.OrderStatus) bool {
+	paid, err := dao.SumOrderDepositPayments(order.ID)
+	if err != nil {
+		log.Errorf("SumOrderDepositPayments %s err:%s", order.ID, err.Error())
+		return false
+	}
+
+	if paid == 0 {
+		return false
+	}
+
+	err = dao.CreditOrderDeposits(order, order.Status, to)
+	if err != nil {
+		log.Errorf("CreditOrderDeposits %s err:%s", order.ID, err.Error())
+		return true
+	}
+
+	log.Infof("order %s moved to status %d, credited its deposits to %s", order.ID, to, order.Account)
+	return true
+}
@@ -0,0 +1,157 @@
+package order
+
+import (
+	"fmt"
+
+	"titan-container-platform/core"
+)
+
+const (
+	// DimensionCPU is the number of cpu cores.
+	DimensionCPU = "cpu"
+	// DimensionRAM is the ram size in GB.
+	DimensionRAM = "ram"
+	// DimensionStorage is the persistent storage size in GB.
+	DimensionStorage = "storage"
+	// DimensionLoadBalancers is the number of public LoadBalancer services.
+	DimensionLoadBalancers = "loadbalancers"
+	// DimensionPods is the number of pods.
+	DimensionPods = "pods"
+	// DimensionEphemeralStorage is the ephemeral storage size in GB.
+	DimensionEphemeralStorage = "ephemeral_storage"
+	// DimensionSSDStorage is the storage size in GB on the ssd storage class.
+	DimensionSSDStorage = "ssd_storage"
+)
+
+// Dimension describes a billable resource dimension of an order.
+type Dimension struct {
+	Name string
+	Min  int
+	Max  int
+	// Price returns the hourly price of the amount under the pricing model.
+	Price func(m *Model, amount int) int
+	// QuotaKeys maps KubeSphere resource quota keys to the format of their values.
+	QuotaKeys map[string]string
+}
+
+var dimensions = make(map[string]*Dimension)
+
+// RegisterDimension adds a billable dimension to the registry, replacing any with the same name.
+func RegisterDimension(d *Dimension) {
+	dimensions[d.Name] = d
+}
+
+func init() {
+	RegisterDimension(&Dimension{
+		Name:      DimensionCPU,
+		Min:       1,
+		Max:       32,
+		Price:     modelPrice(DimensionCPU),
+		QuotaKeys: map[string]string{"limits.cpu": "%d", "requests.cpu": "%d"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionRAM,
+		Min:       1,
+		Max:       64,
+		Price:     modelPrice(DimensionRAM),
+		QuotaKeys: map[string]string{"limits.memory": "%dGi", "requests.memory": "%dGi"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionStorage,
+		Min:       40,
+		Max:       4000,
+		Price:     modelPrice(DimensionStorage),
+		QuotaKeys: map[string]string{"requests.storage": "%dGi", "persistentvolumeclaims": "%d"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionLoadBalancers,
+		Min:       0,
+		Max:       5,
+		Price:     modelPrice(DimensionLoadBalancers),
+		QuotaKeys: map[string]string{"services.loadbalancers": "%d"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionPods,
+		Min:       0,
+		Max:       500,
+		Price:     modelPrice(DimensionPods),
+		QuotaKeys: map[string]string{"pods": "%d"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionEphemeralStorage,
+		Min:       0,
+		Max:       500,
+		Price:     modelPrice(DimensionEphemeralStorage),
+		QuotaKeys: map[string]string{"requests.ephemeral-storage": "%dGi", "limits.ephemeral-storage": "%dGi"},
+	})
+	RegisterDimension(&Dimension{
+		Name:      DimensionSSDStorage,
+		Min:       0,
+		Max:       2000,
+		Price:     modelPrice(DimensionSSDStorage),
+		QuotaKeys: map[string]string{"ssd.storageclass.storage.k8s.io/requests.storage": "%dGi"},
+	})
+}
+
+// modelPrice prices a dimension by the base price and tiers of the model.
+func modelPrice(name string) func(*Model, int) int {
+	return func(m *Model, amount int) int {
+		return m.DimensionPrice(name, amount)
+	}
+}
+
+// RequestDimensions returns all billable dimensions of the order request.
+func RequestDimensions(req *core.OrderReq) core.Dimensions {
+	return mergeDimensions(req.CPUCores, req.RAMSize, req.StorageSize, req.Dimensions)
+}
+
+// OrderDimensions returns all billable dimensions of the order.
+func OrderDimensions(order *core.Order) core.Dimensions {
+	return mergeDimensions(order.CPUCores, order.RAMSize, order.StorageSize, order.Dimensions)
+}
+
+func mergeDimensions(cpu, ram, storage int, extra core.Dimensions) core.Dimensions {
+	out := make(core.Dimensions, len(extra)+3)
+	for name, amount := range extra {
+		out[name] = amount
+	}
+
+	out[DimensionCPU] = cpu
+	out[DimensionRAM] = ram
+	out[DimensionStorage] = storage
+
+	return out
+}
+
+// CheckDimensions reports whether every dimension is registered and within its range.
+func CheckDimensions(dims core.Dimensions) bool {
+	for name, amount := range dims {
+		d, ok := dimensions[name]
+		if !ok {
+			return false
+		}
+
+		if amount < d.Min || amount > d.Max {
+			return false
+		}
+	}
+
+	return true
+}
+
+// QuotaHard renders the KubeSphere resource quota hard limits of the dimensions.
+func QuotaHard(dims core.Dimensions) map[string]string {
+	hard := make(map[string]string)
+	for name, amount := range dims {
+		d, ok := dimensions[name]
+		if !ok || amount <= 0 {
+			continue
+		}
+
+		for key, format := range d.QuotaKeys {
+			hard[key] = fmt.Sprintf(format, amount)
+		}
+	}
+
+	return hard
+}
@@ -0,0 +1,442 @@
+package order
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"titan-container-platform/chain"
+	"titan-container-platform/config"
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+
+	"github.com/google/uuid"
+)
+
+// The flows run against the MySQL database of TEST_DATABASE_URL, a DSN like the DatabaseURL
+// of the config with parseTime=true, and are skipped without it. Each run uses a network of
+// its own, so the rows of earlier runs are left out.
+
+const testAccount = "titan1fakeuser"
+
+var dbOnce sync.Once
+
+func testDB(t *testing.T) {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	var err error
+	dbOnce.Do(func() {
+		cfg := &config.Config{DatabaseURL: url}
+		cfg.ChainAPI.Network = "test-" + uuid.NewString()[:8]
+		err = dao.Init(cfg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newFlow sets up a fake chain with the account holding the tokens, and an indexer
+// mirroring its orders.
+func newFlow(t *testing.T, tokens string) (*chain.FakeClient, *chain.Indexer) {
+	t.Helper()
+	testDB(t)
+
+	f := chain.NewFakeClient(dao.ChainStore{})
+	if err := f.SetBalance(testAccount, tokens); err != nil {
+		t.Fatal(err)
+	}
+	chainClient = f
+
+	return f, chain.NewIndexer(f, dao.ChainStore{})
+}
+
+func createOrder(t *testing.T, mode core.OrderMode, price int, status core.OrderStatus) *core.Order {
+	t.Helper()
+
+	order := &core.Order{
+		ID:          uuid.NewString(),
+		Account:     testAccount,
+		CPUCores:    2,
+		RAMSize:     4,
+		StorageSize: 50,
+		Duration:    1,
+		Price:       price,
+		SurgeFactor: 100,
+		Mode:        mode,
+		Status:      status,
+	}
+	if err := dao.CreateOrder(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+
+	// the payment timeout counts from the creation time of the row
+	return getOrder(t, order.ID)
+}
+
+func getOrder(t *testing.T, id string) *core.Order {
+	t.Helper()
+
+	order, err := dao.GetOrder(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return order
+}
+
+// handleBlock hands the events of the last block to the indexer and then to the order manager.
+func handleBlock(t *testing.T, f *chain.FakeClient, x *chain.Indexer) {
+	t.Helper()
+
+	height, err := f.LatestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := f.BlockEvents(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range events {
+		x.HandleEvent(e)
+		if e.Type == chain.EventDepositTransfer {
+			handleDepositTransfer(e)
+		}
+	}
+}
+
+// confirmTx marks a recorded tx as confirmed, as the Tracker does once it is in a block.
+func confirmTx(t *testing.T, hash string) {
+	t.Helper()
+
+	err := dao.UpdateChainTxResult(&core.ChainTx{Hash: hash, Status: core.TxStatusConfirmed})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertBalance(t *testing.T, f *chain.FakeClient, want string) {
+	t.Helper()
+
+	got, err := f.GetBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("token balance %s, want %s", got, want)
+	}
+}
+
+func TestOrderPaymentFlow(t *testing.T) {
+	f, x := newFlow(t, "1500")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusCreated)
+
+	hash, err := SubmitPayment(order, []byte("signed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+	assertBalance(t, f, "500")
+
+	paid := checkOrdersPaid([]*core.Order{order})
+	if len(paid) != 1 {
+		t.Fatalf("%d orders paid, want 1", len(paid))
+	}
+
+	info := getOrder(t, order.ID)
+	if info.Status != core.OrderStatusPaid || info.TxHash != hash || info.Blocks == 0 {
+		t.Errorf("unexpected order %+v", info)
+	}
+}
+
+func TestUnpaidOrderStaysCreated(t *testing.T) {
+	newFlow(t, "0")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusCreated)
+
+	if paid := checkOrdersPaid([]*core.Order{order}); len(paid) != 0 {
+		t.Fatalf("%d orders paid, want 0", len(paid))
+	}
+
+	if info := getOrder(t, order.ID); info.Status != core.OrderStatusCreated {
+		t.Errorf("order status %d, want %d", info.Status, core.OrderStatusCreated)
+	}
+}
+
+func TestDepositPaymentFlow(t *testing.T) {
+	f, x := newFlow(t, "1500")
+
+	// a deposit order tops up the balance, so it is provisioned without the cluster
+	order := createOrder(t, core.OrderModeDeposit, 1000, core.OrderStatusCreated)
+
+	before, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Deposit(testAccount, order.ID, "600"); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+
+	if info := getOrder(t, order.ID); info.Status != core.OrderStatusCreated {
+		t.Fatalf("under-paid order status %d, want %d", info.Status, core.OrderStatusCreated)
+	}
+
+	if _, err := f.Deposit(testAccount, order.ID, "600"); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+	assertBalance(t, f, "300")
+
+	if info := getOrder(t, order.ID); info.Status != core.OrderStatusPaid {
+		t.Fatalf("paid order status %d, want %d", info.Status, core.OrderStatusPaid)
+	}
+
+	provisionOrder(getOrder(t, order.ID))
+	if info := getOrder(t, order.ID); info.Status != core.OrderStatusDone {
+		t.Errorf("order status %d, want %d", info.Status, core.OrderStatusDone)
+	}
+
+	after, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before != 1200 {
+		t.Errorf("credited %d, want the price and the excess 1200", after-before)
+	}
+
+	// a transfer for an order that is paid already goes to its sender
+	if _, err := f.Deposit(testAccount, order.ID, "100"); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+
+	last, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last-after != 100 {
+		t.Errorf("credited %d, want the late transfer 100", last-after)
+	}
+}
+
+func TestPaidOnChainCreditsDeposits(t *testing.T) {
+	f, x := newFlow(t, "1500")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusCreated)
+
+	before, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Deposit(testAccount, order.ID, "300"); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+
+	if _, err := SubmitPayment(order, []byte("signed")); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+
+	if paid := checkOrdersPaid([]*core.Order{order}); len(paid) != 1 {
+		t.Fatalf("%d orders paid, want 1", len(paid))
+	}
+
+	after, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before != 300 {
+		t.Errorf("credited %d, want the deposit 300", after-before)
+	}
+
+	left, err := dao.SumOrderDepositPayments(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d of deposits left uncredited", left)
+	}
+}
+
+// runOrder puts a paid order of the account on the fake order contract that lasted
+// the blocks and is over, and mirrors it.
+func runOrder(t *testing.T, f *chain.FakeClient, x *chain.Indexer, order *core.Order, blocks uint64) {
+	t.Helper()
+
+	height, err := f.LatestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &chain.TokenOrder{
+		ID:          order.ID,
+		Duration:    blocks,
+		Initiator:   order.Account,
+		LockedFunds: uint64(order.Price),
+		StartHeight: uint64(height),
+		Status:      "running",
+	}
+	f.SetOrder(o)
+
+	for i := uint64(0); i <= blocks; i++ {
+		f.AddBlock()
+	}
+
+	x.HandleEvent(&chain.Event{Type: chain.EventFundsLocked, OrderID: order.ID})
+}
+
+func TestSettlementFlow(t *testing.T) {
+	for _, status := range []core.OrderStatus{core.OrderStatusDone, core.OrderStatusExpired} {
+		t.Run(strconv.Itoa(int(status)), func(t *testing.T) {
+			f, x := newFlow(t, "0")
+			order := createOrder(t, core.OrderModePrepaid, 1000, status)
+			runOrder(t, f, x, order, 10)
+
+			settleOrders()
+
+			settled, err := dao.SumOrderSettlements(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settled != 1000 {
+				t.Fatalf("settled %d, want 1000", settled)
+			}
+
+			orders, err := f.GetOrders([]string{order.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(orders) != 1 || orders[0].LockedFunds != 0 {
+				t.Errorf("unexpected chain orders %+v", orders)
+			}
+
+			// a settled order is not settled again
+			settleOrders()
+
+			settled, err = dao.SumOrderSettlements(order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settled != 1000 {
+				t.Errorf("settled %d after a second pass, want 1000", settled)
+			}
+		})
+	}
+}
+
+func TestSettlementAmountFromTx(t *testing.T) {
+	f, x := newFlow(t, "0")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusDone)
+	runOrder(t, f, x, order, 10)
+
+	// the contract holds less than the estimate, the settlement keeps what it released
+	orders, err := f.GetOrders([]string{order.ID})
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("GetOrders %v err %v", orders, err)
+	}
+	o := *orders[0]
+	o.LockedFunds = 900
+	f.SetOrder(&o)
+
+	settleOrders()
+
+	txs, err := dao.LoadChainTxsByStatus(core.TxStatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range txs {
+		if tx.OrderID == order.ID && tx.Purpose == core.TxPurposeOrderSettle {
+			confirmTx(t, tx.Hash)
+		}
+	}
+
+	confirmSettlements()
+
+	settled, err := dao.SumOrderSettlements(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled != 900 {
+		t.Errorf("settled %d, want the released 900", settled)
+	}
+}
+
+func TestRefundFlow(t *testing.T) {
+	f, x := newFlow(t, "1000")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusCreated)
+
+	if _, err := SubmitPayment(order, []byte("signed")); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+	assertBalance(t, f, "0")
+
+	if err := dao.UpdateOrderStatus(order.ID, core.OrderStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	refundOrders()
+	assertBalance(t, f, "1000")
+
+	info := getOrder(t, order.ID)
+	if info.RefundHash == "" || info.Status != core.OrderStatusFailed {
+		t.Fatalf("unexpected order %+v", info)
+	}
+
+	// the refund is not sent again while its tx is pending
+	refundOrders()
+	if again := getOrder(t, order.ID); again.RefundHash != info.RefundHash {
+		t.Errorf("refund hash %s changed to %s", info.RefundHash, again.RefundHash)
+	}
+
+	confirmTx(t, info.RefundHash)
+	refundOrders()
+
+	if info = getOrder(t, order.ID); info.Status != core.OrderStatusRefunded {
+		t.Errorf("order status %d, want %d", info.Status, core.OrderStatusRefunded)
+	}
+}
+
+func TestFailedDepositOrderRefundsBalance(t *testing.T) {
+	f, x := newFlow(t, "1000")
+	order := createOrder(t, core.OrderModePrepaid, 1000, core.OrderStatusCreated)
+
+	before, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Deposit(testAccount, order.ID, "400"); err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(t, f, x)
+
+	if err := dao.UpdateOrderStatus(order.ID, core.OrderStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	refundOrders()
+
+	if info := getOrder(t, order.ID); info.Status != core.OrderStatusRefunded {
+		t.Errorf("order status %d, want %d", info.Status, core.OrderStatusRefunded)
+	}
+
+	after, err := dao.GetAccountBalance(testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before != 400 {
+		t.Errorf("credited %d, want the deposit 400", after-before)
+	}
+}
@@ -1,8 +1,13 @@
 package order
 
 import (
+	"context"
+	"database/sql"
+	"sync"
 	"time"
 
+	"titan-container-platform/chain"
+	"titan-container-platform/config"
 	"titan-container-platform/core"
 	"titan-container-platform/core/dao"
 	"titan-container-platform/kubesphere"
@@ -13,12 +18,45 @@ import (
 var log = logging.Logger("order")
 
 const (
-	timeInterval = 2 * time.Minute
+	timeInterval   = 2 * time.Minute
+	paymentTimeout = 30 * time.Minute
+)
+
+var (
+	pricingCfg  config.PricingConfig
+	chainClient chain.Client
+
+	// orderLock serializes the payment of orders between the timer and chain events.
+	orderLock sync.Mutex
+	// provisionLock serializes the provisioning of the paid orders between the timer and the provisioner.
+	provisionLock sync.Mutex
+	// meteringLock serializes the status changes of metered orders between billing, deposits and closing.
+	meteringLock sync.Mutex
+	// provisionSignal wakes the provisioner when chain events mark orders paid.
+	provisionSignal = make(chan struct{}, 1)
 )
 
 // Init initializes the order manager.
-func Init() {
+func Init(cfg *config.PricingConfig, client chain.Client) error {
+	pricingCfg = *cfg
+	chainClient = client
+
+	if cfg.ModelFile != "" {
+		m, err := LoadModel(cfg.ModelFile)
+		if err != nil {
+			return err
+		}
+
+		activeModel = m
+	}
+
 	go startTimer()
+	go startProvisioner()
+	go startMeteringTimer()
+	go startSettlementTimer()
+	go startRefundTimer()
+
+	return nil
 }
 
 func startTimer() {
@@ -28,11 +66,33 @@ func startTimer() {
 	for {
 		<-ticker.C
 
+		orderLock.Lock()
 		checkOrderPaid()
-		createSpaceFromOrders()
+		orderLock.Unlock()
+
+		provisionPaidOrders()
+		billCommitmentPeriods()
+	}
+}
+
+// startProvisioner provisions the paid orders whenever a chain event marks some paid,
+// so the watcher does not wait on the cluster.
+func startProvisioner() {
+	for range provisionSignal {
+		provisionPaidOrders()
 	}
 }
 
+// wakeProvisioner asks the provisioner for a run, the runs requested meanwhile are merged.
+func wakeProvisioner() {
+	select {
+	case provisionSignal <- struct{}{}:
+	default:
+	}
+}
+
+// checkOrderPaid marks the created orders whose funds are locked on the order contract
+// as paid, and times out the ones that were not paid in time.
 func checkOrderPaid() {
 	list, err := dao.LoadOrdersByStatus(core.OrderStatusCreated)
 	if err != nil {
@@ -40,15 +100,102 @@ func checkOrderPaid() {
 		return
 	}
 
+	checkOrdersPaid(list)
+}
+
+// checkOrdersPaid updates the status of the created orders and returns the ones that are paid.
+func checkOrdersPaid(list []*core.Order) []*core.Order {
+	if len(list) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(list))
 	for _, order := range list {
-		// if time < xxx set status = OrderStatusTimeout
-		// TODO check order from TOKEN
-		// if true
-		err := dao.UpdateOrderStatus(order.ID, core.OrderStatusPaid)
-		if err != nil {
-			log.Errorf("UpdateOrderStatus %s err:%s", order.ID, err.Error())
+		ids = append(ids, order.ID)
+	}
+
+	// the indexer refreshes the mirrored order of a payment event before this runs
+	tokenOrders, err := dao.LoadChainOrdersByIDs(ids)
+	if err != nil {
+		log.Errorf("LoadChainOrdersByIDs err:%s", err.Error())
+		return nil
+	}
+
+	locked := make(map[string]*core.ChainOrder, len(tokenOrders))
+	for _, o := range tokenOrders {
+		locked[o.ID] = o
+	}
+
+	var paid []*core.Order
+	for _, order := range list {
+		if o, ok := locked[order.ID]; ok && o.LockedFunds >= uint64(order.Price) {
+			// deposits that partly paid the order before are not needed any more
+			credited, err := dao.PayOrder(order)
+			if err != nil {
+				log.Errorf("PayOrder %s err:%s", order.ID, err.Error())
+				continue
+			}
+
+			if credited > 0 {
+				log.Infof("order %s is paid on chain, credited its deposits %d to %s", order.ID, credited, order.Account)
+			}
+
+			order.Status = core.OrderStatusPaid
+			paid = append(paid, order)
+			continue
+		}
+
+		if time.Since(order.CreatedAt) > paymentTimeout && !creditDeposits(order, core.OrderStatusTimeout) {
+			updateOrderStatus(order.ID, core.OrderStatusTimeout)
 		}
 	}
+
+	return paid
+}
+
+// HandleChainEvent reacts to payments on the order contract and to the deposit
+// address by marking the order paid right away, instead of waiting for the timer.
+// The provisioner creates its workspace apart.
+func HandleChainEvent(e *chain.Event) {
+	switch e.Type {
+	case chain.EventOrderCreated, chain.EventFundsLocked:
+	case chain.EventDepositTransfer:
+		handleDepositTransfer(e)
+		return
+	default:
+		return
+	}
+
+	if e.OrderID == "" {
+		return
+	}
+
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	info, err := dao.GetOrder(context.Background(), e.OrderID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("GetOrder %s err:%s", e.OrderID, err.Error())
+		}
+		return
+	}
+
+	if info.Status != core.OrderStatusCreated || info.Network != dao.Network() {
+		return
+	}
+
+	if len(checkOrdersPaid([]*core.Order{info})) > 0 {
+		wakeProvisioner()
+	}
+}
+
+// provisionPaidOrders provisions the paid orders, one run at a time.
+func provisionPaidOrders() {
+	provisionLock.Lock()
+	defer provisionLock.Unlock()
+
+	createSpaceFromOrders()
 }
 
 func createSpaceFromOrders() {
@@ -59,18 +206,38 @@ func createSpaceFromOrders() {
 	}
 
 	for _, order := range list {
-		status := core.OrderStatusDone
+		provisionOrder(order)
+	}
+}
 
-		err = kubesphere.CreateSpaceAndResourceQuotas(order.ID, order.Account, order.CPUCores, order.RAMSize, order.StorageSize)
-		if err != nil {
-			log.Errorf("LoadOrdersByStatus err:%s", err.Error())
+// provisionOrder creates the workspace of a paid order, or credits a paid deposit.
+func provisionOrder(order *core.Order) {
+	if order.Mode == core.OrderModeDeposit {
+		creditDeposit(order)
+		return
+	}
 
-			status = core.OrderStatusExpired
-		}
+	status := core.OrderStatusDone
+
+	err := kubesphere.CreateSpaceAndResourceQuotas(order.ID, order.Account, QuotaHard(OrderDimensions(order)))
+	if err != nil {
+		log.Errorf("CreateSpaceAndResourceQuotas %s err:%s", order.ID, err.Error())
 
-		err := dao.UpdateOrderStatus(order.ID, status)
+		status = core.OrderStatusFailed
+	} else if order.Plan != "" {
+		// the first period starts once the space is ready
+		err = dao.UpdateOrderPeriod(order.ID, 1, time.Now().Add(time.Duration(order.Duration)*time.Hour))
 		if err != nil {
-			log.Errorf("UpdateOrderStatus %s err:%s", order.ID, err.Error())
+			log.Errorf("UpdateOrderPeriod %s err:%s", order.ID, err.Error())
 		}
 	}
+
+	updateOrderStatus(order.ID, status)
+}
+
+func updateOrderStatus(id string, status core.OrderStatus) {
+	err := dao.UpdateOrderStatus(id, status)
+	if err != nil {
+		log.Errorf("UpdateOrderStatus %s err:%s", id, err.Error())
+	}
 }
@@ -0,0 +1,182 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+	"titan-container-platform/kubesphere"
+)
+
+const (
+	meteringInterval = time.Hour
+)
+
+// ErrOrderNotClosable is returned when an order is not a running or suspended metered order.
+var ErrOrderNotClosable = errors.New("order is not a running or suspended metered order")
+
+func startMeteringTimer() {
+	ticker := time.NewTicker(meteringInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		billMeteredOrders()
+	}
+}
+
+// billMeteredOrders samples the usage of every running metered order, debits the
+// cost of the past hour from the account balance, and suspends the workspaces of
+// accounts that ran out of balance. A suspension that fails is retried the next hour.
+func billMeteredOrders() {
+	meteringLock.Lock()
+	defer meteringLock.Unlock()
+
+	list, err := dao.LoadOrdersByModeAndStatus(core.OrderModeMetered, core.OrderStatusDone)
+	if err != nil {
+		log.Errorf("LoadOrdersByModeAndStatus err:%s", err.Error())
+		return
+	}
+
+	hour := time.Now().Truncate(time.Hour)
+	for _, order := range list {
+		usage, err := kubesphere.GetWorkspaceUsage(order.ID)
+		if err != nil {
+			log.Errorf("GetWorkspaceUsage %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		record := &core.UsageRecord{
+			OrderID: order.ID,
+			Account: order.Account,
+			Hour:    hour,
+			CPU:     usage.CPU,
+			RAM:     usage.RAM,
+			Storage: usage.Storage,
+			Cost:    usageCost(usage, order.SurgeFactor),
+		}
+
+		balance, _, err := dao.RecordUsage(record)
+		if err != nil {
+			log.Errorf("RecordUsage %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		if balance <= 0 {
+			suspendMeteredOrder(order)
+		}
+	}
+}
+
+// usageCost prices an hour of usage at the base prices, without volume tiers.
+func usageCost(usage *kubesphere.WorkspaceUsage, surgeFactor int) int {
+	cost := usage.CPU*float64(activeModel.BasePrices[DimensionCPU]) +
+		usage.RAM*float64(activeModel.BasePrices[DimensionRAM]) +
+		usage.Storage*float64(activeModel.BasePrices[DimensionStorage])
+
+	return int(math.Ceil(cost * float64(surgeFactor) / noSurgeFactor))
+}
+
+// suspendMeteredOrder zeroes the resource quota of the workspace so no new pods can be
+// scheduled, and stops its running workloads. The order stays running, and billed, until
+// the workloads are stopped.
+func suspendMeteredOrder(order *core.Order) {
+	hard := QuotaHard(OrderDimensions(order))
+	for key := range hard {
+		hard[key] = "0"
+	}
+
+	err := kubesphere.UpdateResourceQuotas(order.ID, hard)
+	if err != nil {
+		log.Errorf("UpdateResourceQuotas %s err:%s", order.ID, err.Error())
+		return
+	}
+
+	err = kubesphere.StopWorkloads(order.ID)
+	if err != nil {
+		log.Errorf("StopWorkloads %s err:%s", order.ID, err.Error())
+		return
+	}
+
+	updateOrderStatus(order.ID, core.OrderStatusSuspended)
+}
+
+// resumeMeteredOrders restores the resource quotas and restarts the workloads of the suspended metered orders of an account.
+func resumeMeteredOrders(account string) {
+	meteringLock.Lock()
+	defer meteringLock.Unlock()
+
+	list, err := dao.LoadAccountOrdersByModeAndStatus(account, core.OrderModeMetered, core.OrderStatusSuspended)
+	if err != nil {
+		log.Errorf("LoadAccountOrdersByModeAndStatus err:%s", err.Error())
+		return
+	}
+
+	for _, order := range list {
+		err := kubesphere.UpdateResourceQuotas(order.ID, QuotaHard(OrderDimensions(order)))
+		if err != nil {
+			log.Errorf("UpdateResourceQuotas %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		err = kubesphere.StartWorkloads(order.ID)
+		if err != nil {
+			log.Errorf("StartWorkloads %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		updateOrderStatus(order.ID, core.OrderStatusDone)
+	}
+}
+
+// CloseMeteredOrder stops the workloads of a running or suspended metered order, removes
+// the resource quota of its workspace and closes the order, which ends its billing.
+// It can be called again if stopping the workloads or removing the quota fails.
+func CloseMeteredOrder(id string) error {
+	meteringLock.Lock()
+	defer meteringLock.Unlock()
+
+	order, err := dao.GetOrder(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	if order.Mode != core.OrderModeMetered || (order.Status != core.OrderStatusDone && order.Status != core.OrderStatusSuspended) {
+		return ErrOrderNotClosable
+	}
+
+	err = kubesphere.StopWorkloads(order.ID)
+	if err != nil {
+		return err
+	}
+
+	err = kubesphere.DeleteResourceQuotas(order.ID)
+	if err != nil {
+		return err
+	}
+
+	return dao.MoveOrderStatus(order.ID, order.Status, core.OrderStatusClosed)
+}
+
+// creditDeposit adds a paid deposit order to the account balance.
+func creditDeposit(order *core.Order) {
+	err := dao.CreditDepositOrder(order)
+	if err != nil {
+		log.Errorf("CreditDepositOrder %s err:%s", order.ID, err.Error())
+		return
+	}
+
+	balance, err := dao.GetAccountBalance(order.Account)
+	if err != nil {
+		log.Errorf("GetAccountBalance %s err:%s", order.Account, err.Error())
+		return
+	}
+
+	if balance > 0 {
+		resumeMeteredOrders(order.Account)
+	}
+}
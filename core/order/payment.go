@@ -0,0 +1,68 @@
+package order
+
+import (
+	"strconv"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+
+	chaintypes "github.com/Titannet-dao/titan-chain/x/wasm/types"
+)
+
+// PaymentMsg builds the unsigned msg with which the account of the order pays for it from its own tokens.
+// The duration in blocks is fixed the first time, so the msg signed by the user matches the one checked
+// on submission even when the block time changes in between.
+func PaymentMsg(info *core.Order) (*chaintypes.MsgExecuteContract, error) {
+	if info.Blocks == 0 {
+		blocks, err := dao.SetOrderBlocks(info.ID, chainClient.HoursToBlocks(info.Duration))
+		if err != nil {
+			return nil, err
+		}
+		info.Blocks = blocks
+	}
+
+	return chainClient.OrderPaymentMsg(info.Account, info.ID, info.CPUCores, info.RAMSize, info.StorageSize, info.Blocks, strconv.Itoa(info.Price))
+}
+
+// AllowanceMsg builds the unsigned msg with which the account of a commitment order lets the
+// service renew it for the periods after the first, to be signed in the tx of its payment msg.
+// It is nil for the orders that are paid at once.
+func AllowanceMsg(info *core.Order) (*chaintypes.MsgExecuteContract, error) {
+	if info.Plan == "" || info.Periods <= 1 {
+		return nil, nil
+	}
+
+	amount := int64(info.Price) * int64(info.Periods-1)
+	return chainClient.RenewalAllowanceMsg(info.Account, strconv.FormatInt(amount, 10))
+}
+
+// SubmitPayment broadcasts the user-signed payment tx of the order and links its hash to the order.
+// The tx must carry the allowance msg of the order too, when it has one.
+func SubmitPayment(info *core.Order, txBytes []byte) (string, error) {
+	payment, err := PaymentMsg(info)
+	if err != nil {
+		return "", err
+	}
+
+	msgs := []*chaintypes.MsgExecuteContract{payment}
+
+	allowance, err := AllowanceMsg(info)
+	if err != nil {
+		return "", err
+	}
+	if allowance != nil {
+		msgs = append(msgs, allowance)
+	}
+
+	hash, err := chainClient.BroadcastOrderPayment(info.ID, txBytes, msgs...)
+	if err != nil {
+		return "", err
+	}
+
+	err = dao.UpdateOrderTxHash(info.ID, hash)
+	if err != nil {
+		log.Errorf("UpdateOrderTxHash %s err:%s", info.ID, err.Error())
+	}
+
+	return hash, nil
+}
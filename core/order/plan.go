@@ -0,0 +1,141 @@
+package order
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+const (
+	// PlanMonthly is billed every 30 days.
+	PlanMonthly = "monthly"
+	// PlanQuarterly is billed every 90 days.
+	PlanQuarterly = "quarterly"
+	// PlanYearly is billed every 365 days.
+	PlanYearly = "yearly"
+)
+
+const (
+	// renewLeadTime is how long before the end of a period the next one is billed.
+	renewLeadTime = time.Hour
+	// renewGracePeriod is how long after the end of a period a failed renewal is retried before the order expires.
+	renewGracePeriod = 24 * time.Hour
+)
+
+// Plan describes a long-term commitment plan that is billed in recurring periods.
+type Plan struct {
+	Name        string
+	PeriodHours int
+	Coefficient int // in percent
+	MaxPeriods  int
+}
+
+var plans = map[string]*Plan{
+	PlanMonthly:   {Name: PlanMonthly, PeriodHours: 30 * 24, Coefficient: 65, MaxPeriods: 36},
+	PlanQuarterly: {Name: PlanQuarterly, PeriodHours: 90 * 24, Coefficient: 60, MaxPeriods: 12},
+	PlanYearly:    {Name: PlanYearly, PeriodHours: 365 * 24, Coefficient: 50, MaxPeriods: 3},
+}
+
+// CheckPlan reports whether the plan exists and allows the number of periods.
+func CheckPlan(name string, periods int) bool {
+	plan, ok := plans[name]
+	if !ok {
+		return false
+	}
+
+	return periods >= 1 && periods <= plan.MaxPeriods
+}
+
+// billCommitmentPeriods charges the next period of running commitment orders
+// against the order contract, and expires the ones whose periods are used up.
+// A period is only credited once its renewal tx is confirmed on chain. The renewal
+// tx is linked to the order when it is recorded, before it is broadcast.
+func billCommitmentPeriods() {
+	list, err := dao.LoadPlanOrdersByStatus(core.OrderStatusDone)
+	if err != nil {
+		log.Errorf("LoadPlanOrdersByStatus err:%s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, order := range list {
+		if order.RenewHash != "" {
+			checkRenewal(order, now)
+			continue
+		}
+
+		if now.Before(order.PeriodEnd.Add(-renewLeadTime)) {
+			continue
+		}
+
+		if order.PaidPeriods >= order.Periods {
+			if now.After(order.PeriodEnd) {
+				updateOrderStatus(order.ID, core.OrderStatusExpired)
+			}
+			continue
+		}
+
+		hash, err := chainClient.RenewOrder(order.Account, order.ID, chainClient.HoursToBlocks(order.Duration), strconv.Itoa(order.Price))
+		if err != nil {
+			log.Errorf("RenewOrder %s err:%s", order.ID, err.Error())
+
+			expireUnsent(order, now)
+			continue
+		}
+
+		log.Infof("renew order %s tx %s", order.ID, hash)
+	}
+}
+
+// expireUnsent expires the order past the grace period when its renewal was not sent.
+// A renewal that was recorded may still be included, so it is left to checkRenewal.
+func expireUnsent(order *core.Order, now time.Time) {
+	info, err := dao.GetOrder(context.Background(), order.ID)
+	if err != nil {
+		log.Errorf("GetOrder %s err:%s", order.ID, err.Error())
+		return
+	}
+
+	if info.RenewHash == "" {
+		expireUnrenewed(order, now)
+	}
+}
+
+// checkRenewal credits the next period of the order once its renewal tx is confirmed,
+// and clears the renewal when the tx failed so that it is sent again.
+func checkRenewal(order *core.Order, now time.Time) {
+	tx, err := serviceTx(order.RenewHash)
+	if err != nil {
+		log.Errorf("serviceTx %s err:%s", order.RenewHash, err.Error())
+		return
+	}
+
+	switch tx.Status {
+	case core.TxStatusConfirmed:
+		periodEnd := order.PeriodEnd.Add(time.Duration(order.Duration) * time.Hour)
+		err = dao.FinishOrderRenewal(order.ID, order.RenewHash, order.PaidPeriods+1, periodEnd)
+		if err != nil {
+			log.Errorf("FinishOrderRenewal %s err:%s", order.ID, err.Error())
+		}
+	case core.TxStatusFailed:
+		log.Warnf("renewal of order %s failed: %s", order.ID, tx.Log)
+
+		err = dao.UpdateOrderRenewHash(order.ID, "")
+		if err != nil {
+			log.Errorf("UpdateOrderRenewHash %s err:%s", order.ID, err.Error())
+			return
+		}
+
+		expireUnrenewed(order, now)
+	}
+}
+
+// expireUnrenewed expires the order when its renewal kept failing past the grace period.
+func expireUnrenewed(order *core.Order, now time.Time) {
+	if now.After(order.PeriodEnd.Add(renewGracePeriod)) {
+		updateOrderStatus(order.ID, core.OrderStatusExpired)
+	}
+}
@@ -1,95 +1,190 @@
 package order
 
 import (
+	"fmt"
+	"sort"
+
 	"titan-container-platform/core"
-)
 
-const (
-	baseCPUPrice     = 100
-	baseRAMPrice     = 100
-	baseStoragePrice = 2
+	"github.com/spf13/viper"
 )
 
-func calculateCPUPrice(cores int) int {
-	switch {
-	case cores >= 1 && cores <= 4:
-		return cores * baseCPUPrice * 1
-	case cores >= 5 && cores <= 8:
-		return (cores * baseCPUPrice * 9) / 10
-	case cores >= 9 && cores <= 16:
-		return (cores * baseCPUPrice * 8) / 10
-	case cores >= 17 && cores <= 32:
-		return (cores * baseCPUPrice * 7) / 10
-	default:
-		return 0
-	}
+// Tier applies a rate in percent to the amounts within [Min, Max].
+type Tier struct {
+	Min  int
+	Max  int
+	Rate int
+}
+
+// Model holds the hourly unit prices and the discount tiers that orders are priced with.
+type Model struct {
+	Version string
+	// BasePrices maps dimension names to their hourly unit price.
+	BasePrices map[string]int
+	// Tiers maps dimension names to their volume tiers. Amounts outside
+	// every tier of a tiered dimension are priced at zero.
+	Tiers map[string][]Tier
+	// DurationTiers holds the duration coefficients by ordered hours.
+	DurationTiers []Tier
 }
 
-func calculateRAMPrice(size int) int {
-	switch {
-	case size >= 1 && size <= 4:
-		return size * baseRAMPrice * 1
-	case size >= 5 && size <= 16:
-		return (size * baseRAMPrice * 9) / 10
-	case size >= 17 && size <= 32:
-		return (size * baseRAMPrice * 8) / 10
-	case size >= 33 && size <= 64:
-		return (size * baseRAMPrice * 7) / 10
-	default:
-		return 0
+// DefaultModel is the built-in pricing model.
+var DefaultModel = &Model{
+	Version: "default",
+	BasePrices: map[string]int{
+		DimensionCPU:              100,
+		DimensionRAM:              100,
+		DimensionStorage:          2,
+		DimensionLoadBalancers:    50,
+		DimensionPods:             1,
+		DimensionEphemeralStorage: 1,
+		DimensionSSDStorage:       4,
+	},
+	Tiers: map[string][]Tier{
+		DimensionCPU: {
+			{Min: 1, Max: 4, Rate: 100},
+			{Min: 5, Max: 8, Rate: 90},
+			{Min: 9, Max: 16, Rate: 80},
+			{Min: 17, Max: 32, Rate: 70},
+		},
+		DimensionRAM: {
+			{Min: 1, Max: 4, Rate: 100},
+			{Min: 5, Max: 16, Rate: 90},
+			{Min: 17, Max: 32, Rate: 80},
+			{Min: 33, Max: 64, Rate: 70},
+		},
+		DimensionStorage: {
+			{Min: 40, Max: 100, Rate: 100},
+			{Min: 101, Max: 500, Rate: 80},
+			{Min: 501, Max: 2000, Rate: 60},
+			{Min: 2001, Max: 4000, Rate: 50},
+		},
+	},
+	DurationTiers: []Tier{
+		{Min: 1, Max: 24, Rate: 100},
+		{Min: 25, Max: 72, Rate: 90},
+		{Min: 73, Max: 168, Rate: 80},
+		{Min: 169, Max: 720, Rate: 70},
+	},
+}
+
+// activeModel is the model that orders are priced with.
+var activeModel = DefaultModel
+
+// LoadModel reads a pricing model from a toml, yaml or json file.
+func LoadModel(path string) (*Model, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var m Model
+	if err := v.Unmarshal(&m); err != nil {
+		return nil, err
+	}
+
+	if len(m.BasePrices) == 0 {
+		return nil, fmt.Errorf("pricing model %s has no base prices", path)
 	}
+
+	return &m, nil
 }
 
-func calculateStoragePrice(size int) int {
-	switch {
-	case size >= 40 && size <= 100:
-		return size * baseStoragePrice * 1
-	case size >= 101 && size <= 500:
-		return (size * baseStoragePrice * 8) / 10
-	case size >= 501 && size <= 2000:
-		return (size * baseStoragePrice * 6) / 10
-	case size >= 2001 && size <= 4000:
-		return (size * baseStoragePrice * 5) / 10
-	default:
-		return 0
+func tierRate(tiers []Tier, amount int) int {
+	for _, t := range tiers {
+		if amount >= t.Min && amount <= t.Max {
+			return t.Rate
+		}
 	}
+
+	return 0
 }
 
-func calculateDurationCoefficient(hours int) int {
-	switch {
-	case hours >= 1 && hours <= 24:
-		return 10
-	case hours >= 25 && hours <= 72:
-		return 9
-	case hours >= 73 && hours <= 168:
-		return 8
-	case hours >= 169 && hours <= 720:
-		return 7
-	default:
-		return 0
+// DimensionPrice returns the hourly price of the amount of a dimension.
+func (m *Model) DimensionPrice(name string, amount int) int {
+	price := amount * m.BasePrices[name]
+
+	tiers, ok := m.Tiers[name]
+	if !ok {
+		return price
 	}
+
+	return price * tierRate(tiers, amount) / 100
 }
 
-// CalculateTotalCost calculates the total cost based on the order request configuration.
-func CalculateTotalCost(config *core.OrderReq) int {
-	cpuCost := calculateCPUPrice(config.CPUCores)
-	ramCost := calculateRAMPrice(config.RAMSize)
-	storageCost := calculateStoragePrice(config.StorageSize)
+// DurationCoefficient returns the coefficient in percent for the ordered hours.
+func (m *Model) DurationCoefficient(hours int) int {
+	return tierRate(m.DurationTiers, hours)
+}
 
-	hourlyBaseCost := cpuCost + ramCost + storageCost
-	durationCoefficient := calculateDurationCoefficient(config.Duration)
+// Quote represents the price breakdown of an order.
+type Quote struct {
+	Costs               map[string]int `json:"costs"` // per hour, by dimension
+	Plan                string         `json:"plan,omitempty"`
+	Duration            int            `json:"duration"`             // billed hours, one period for plans
+	DurationCoefficient int            `json:"duration_coefficient"` // in percent
+	SurgeFactor         int            `json:"surge_factor"`         // in percent
+	Total               int            `json:"total"`
+	// Gaps lists the ordered dimensions, and "duration", that fall outside every tier and are priced at zero.
+	Gaps []string `json:"gaps,omitempty"`
+}
 
-	totalCost := (hourlyBaseCost * config.Duration * durationCoefficient) / 10
+// CalculateQuote calculates the price breakdown of the order request at the current surge factor.
+func CalculateQuote(config *core.OrderReq) *Quote {
+	return activeModel.Quote(config, CurrentSurgeFactor())
+}
+
+// Quote calculates the price breakdown of the order request with the model.
+func (m *Model) Quote(config *core.OrderReq, surgeFactor int) *Quote {
+	quote := &Quote{
+		Costs:       make(map[string]int),
+		SurgeFactor: surgeFactor,
+	}
 
-	log.Infof("Total cost for the configuration: %d test coins\n", totalCost)
+	for name, amount := range RequestDimensions(config) {
+		d, ok := dimensions[name]
+		if !ok || amount <= 0 {
+			continue
+		}
+
+		cost := d.Price(m, amount)
+		if cost == 0 {
+			quote.Gaps = append(quote.Gaps, name)
+		}
+		quote.Costs[name] = cost
+	}
+
+	if plan, ok := plans[config.Plan]; ok {
+		quote.Plan = plan.Name
+		quote.Duration = plan.PeriodHours
+		quote.DurationCoefficient = plan.Coefficient
+	} else {
+		quote.Duration = config.Duration
+		quote.DurationCoefficient = m.DurationCoefficient(config.Duration)
+		if quote.DurationCoefficient == 0 {
+			quote.Gaps = append(quote.Gaps, "duration")
+		}
+	}
 
-	// Detailed breakdown
-	log.Infoln("\nDetailed Breakdown:\n")
-	log.Infof("CPU Cost (per hour): %d\n", cpuCost)
-	log.Infof("RAM Cost (per hour): %d\n", ramCost)
-	log.Infof("Storage Cost (per hour): %d\n", storageCost)
-	log.Infof("Duration Coefficient: %d\n", durationCoefficient)
-	log.Infof("Total Duration: %d hours\n", config.Duration)
+	sort.Strings(quote.Gaps)
 
-	return totalCost // Round to 2 decimal places
+	hourlyBaseCost := 0
+	for _, cost := range quote.Costs {
+		hourlyBaseCost += cost
+	}
+	quote.Total = (hourlyBaseCost * quote.Duration * quote.DurationCoefficient * quote.SurgeFactor) / (100 * noSurgeFactor)
+
+	log.Debugf("Total cost for the configuration: %d test coins, model %s", quote.Total, m.Version)
+	for name, cost := range quote.Costs {
+		log.Debugf("%s Cost (per hour): %d", name, cost)
+	}
+	log.Debugf("Duration Coefficient: %d%%, Surge Factor: %d%%, Total Duration: %d hours", quote.DurationCoefficient, quote.SurgeFactor, quote.Duration)
+
+	return quote
+}
+
+// CalculateTotalCost calculates the total cost based on the order request configuration.
+func CalculateTotalCost(config *core.OrderReq) int {
+	return CalculateQuote(config).Total
 }
@@ -0,0 +1,113 @@
+package order
+
+import (
+	"time"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+const refundInterval = 5 * time.Minute
+
+func startRefundTimer() {
+	ticker := time.NewTicker(refundInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		refundOrders()
+	}
+}
+
+// refundOrders cancels the failed orders, and the timed out orders that were paid
+// late, on the order contract so their locked funds go back to the initiator.
+// A refund is retried until its tx is confirmed, however old the order is. The failed
+// orders paid by deposit transfers are refunded to the balance of the order account instead.
+func refundOrders() {
+	var list []*core.Order
+	for _, status := range []core.OrderStatus{core.OrderStatusFailed, core.OrderStatusTimeout} {
+		orders, err := dao.LoadRefundableOrders(status)
+		if err != nil {
+			log.Errorf("LoadRefundableOrders err:%s", err.Error())
+			return
+		}
+
+		list = append(list, orders...)
+	}
+
+	var unrefunded []*core.Order
+	for _, order := range list {
+		if order.Status == core.OrderStatusFailed && order.RefundHash == "" && creditDeposits(order, core.OrderStatusRefunded) {
+			continue
+		}
+
+		if order.RefundHash == "" {
+			unrefunded = append(unrefunded, order)
+			continue
+		}
+
+		checkRefund(order)
+	}
+
+	if len(unrefunded) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(unrefunded))
+	for _, order := range unrefunded {
+		ids = append(ids, order.ID)
+	}
+
+	tokenOrders, err := dao.LoadChainOrdersByIDs(ids)
+	if err != nil {
+		log.Errorf("LoadChainOrdersByIDs err:%s", err.Error())
+		return
+	}
+
+	locked := make(map[string]*core.ChainOrder, len(tokenOrders))
+	for _, o := range tokenOrders {
+		locked[o.ID] = o
+	}
+
+	for _, order := range unrefunded {
+		if o, ok := locked[order.ID]; !ok || o.LockedFunds == 0 {
+			continue
+		}
+
+		hash, err := chainClient.CancelOrder(order.ID)
+		if err != nil {
+			log.Errorf("CancelOrder %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		log.Infof("refund order %s tx %s", order.ID, hash)
+
+		err = dao.UpdateOrderRefundHash(order.ID, hash)
+		if err != nil {
+			log.Errorf("UpdateOrderRefundHash %s err:%s", order.ID, err.Error())
+		}
+	}
+}
+
+// checkRefund marks the order refunded once its refund tx is confirmed, and
+// clears the refund when the tx failed so that it is sent again.
+func checkRefund(order *core.Order) {
+	tx, err := serviceTx(order.RefundHash)
+	if err != nil {
+		log.Errorf("serviceTx %s err:%s", order.RefundHash, err.Error())
+		return
+	}
+
+	switch tx.Status {
+	case core.TxStatusConfirmed:
+		updateOrderStatus(order.ID, core.OrderStatusRefunded)
+	case core.TxStatusFailed:
+		log.Warnf("refund of order %s failed: %s", order.ID, tx.Log)
+
+		err = dao.UpdateOrderRefundHash(order.ID, "")
+		if err != nil {
+			log.Errorf("UpdateOrderRefundHash %s err:%s", order.ID, err.Error())
+		}
+	}
+}
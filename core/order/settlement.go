@@ -0,0 +1,172 @@
+package order
+
+import (
+	"strconv"
+	"time"
+
+	"titan-container-platform/chain"
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+const (
+	settlementInterval = time.Hour
+	// minSettlement is the smallest earned amount worth a settlement tx, unless the order is over.
+	minSettlement = 100
+)
+
+func startSettlementTimer() {
+	ticker := time.NewTicker(settlementInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		confirmSettlements()
+		settleOrders()
+	}
+}
+
+// confirmSettlements replaces the estimated amount of the settlements whose tx the Tracker
+// confirmed with the amount the order contract released in it.
+func confirmSettlements() {
+	list, err := dao.LoadUnconfirmedSettlements()
+	if err != nil {
+		log.Errorf("LoadUnconfirmedSettlements err:%s", err.Error())
+		return
+	}
+
+	for _, settlement := range list {
+		res, err := chainClient.GetTx(settlement.TxHash)
+		if err != nil {
+			log.Errorf("GetTx %s err:%s", settlement.TxHash, err.Error())
+			continue
+		}
+
+		amount, ok := releasedAmount(res, settlement.OrderID)
+		if !ok {
+			log.Warnf("settle tx %s of order %s has no released amount, keeping the estimate %d", settlement.TxHash, settlement.OrderID, settlement.Amount)
+			amount = settlement.Amount
+		}
+
+		err = dao.ConfirmSettlement(settlement.ID, amount)
+		if err != nil {
+			log.Errorf("ConfirmSettlement %d err:%s", settlement.ID, err.Error())
+		}
+	}
+}
+
+// releasedAmount returns the amount of the settle event of the order in the tx.
+func releasedAmount(res *chain.TxResult, orderID string) (int64, bool) {
+	for _, e := range res.Events {
+		if e.Type != chain.EventOrderSettled || (e.OrderID != "" && e.OrderID != orderID) {
+			continue
+		}
+
+		amount, err := strconv.ParseInt(e.Amount, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return amount, true
+	}
+
+	return 0, false
+}
+
+// settleOrders claims the funds that the running prepaid orders earned since their
+// last settlement from the order contract. Plan orders expire at the end of their last
+// paid period, so the expired orders are settled too until their paid funds are.
+func settleOrders() {
+	list, err := dao.LoadOrdersByModeAndStatus(core.OrderModePrepaid, core.OrderStatusDone)
+	if err != nil {
+		log.Errorf("LoadOrdersByModeAndStatus err:%s", err.Error())
+		return
+	}
+
+	expired, err := dao.LoadUnsettledOrders(core.OrderModePrepaid, core.OrderStatusExpired)
+	if err != nil {
+		log.Errorf("LoadUnsettledOrders err:%s", err.Error())
+	} else {
+		list = append(list, expired...)
+	}
+
+	if len(list) == 0 {
+		return
+	}
+
+	height, err := chainClient.LatestHeight()
+	if err != nil {
+		log.Errorf("LatestHeight err:%s", err.Error())
+		return
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, order := range list {
+		ids = append(ids, order.ID)
+	}
+
+	tokenOrders, err := dao.LoadChainOrdersByIDs(ids)
+	if err != nil {
+		log.Errorf("LoadChainOrdersByIDs err:%s", err.Error())
+		return
+	}
+
+	onChain := make(map[string]*core.ChainOrder, len(tokenOrders))
+	for _, o := range tokenOrders {
+		onChain[o.ID] = o
+	}
+
+	for _, order := range list {
+		o, ok := onChain[order.ID]
+		if !ok || o.Duration == 0 {
+			continue
+		}
+
+		settled, err := dao.SumOrderSettlements(order.ID)
+		if err != nil {
+			log.Errorf("SumOrderSettlements %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		amount := earnedAmount(order, o, height) - settled
+		finished := height >= int64(o.StartHeight+o.Duration)
+		if amount <= 0 || (amount < minSettlement && !finished) {
+			continue
+		}
+
+		hash, err := chainClient.SettleOrder(order.ID)
+		if err != nil {
+			log.Errorf("SettleOrder %s err:%s", order.ID, err.Error())
+			continue
+		}
+
+		err = dao.CreateSettlement(&core.Settlement{
+			OrderID: order.ID,
+			Account: order.Account,
+			Amount:  amount,
+			Height:  height,
+			TxHash:  hash,
+		})
+		if err != nil {
+			log.Errorf("CreateSettlement %s err:%s", order.ID, err.Error())
+		}
+	}
+}
+
+// earnedAmount returns the part of the paid funds of the order that the blocks
+// elapsed since it started on chain have earned.
+func earnedAmount(order *core.Order, o *core.ChainOrder, height int64) int64 {
+	funds := int64(order.Price) * int64(max(order.PaidPeriods, 1))
+
+	elapsed := height - int64(o.StartHeight)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	if elapsed >= int64(o.Duration) {
+		return funds
+	}
+
+	return funds * elapsed / int64(o.Duration)
+}
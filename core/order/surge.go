@@ -0,0 +1,51 @@
+package order
+
+import (
+	"titan-container-platform/core/dao"
+)
+
+const (
+	// noSurgeFactor is the surge factor in percent that leaves the hourly rates unchanged.
+	noSurgeFactor = 100
+)
+
+// CurrentSurgeFactor returns the surge factor in percent for the current cluster allocation.
+func CurrentSurgeFactor() int {
+	if !pricingCfg.SurgeEnabled {
+		return noSurgeFactor
+	}
+
+	cpu, ram, err := dao.SumActiveOrderResources()
+	if err != nil {
+		log.Errorf("SumActiveOrderResources err:%s", err.Error())
+		return noSurgeFactor
+	}
+
+	return calculateSurgeFactor(cpu, ram)
+}
+
+// calculateSurgeFactor grows the factor linearly from 100% at the threshold
+// to MaxSurgeFactor at full allocation of either cpu or ram.
+func calculateSurgeFactor(cpuUsed, ramUsed int) int {
+	if pricingCfg.ClusterCPU <= 0 || pricingCfg.ClusterRAM <= 0 {
+		return noSurgeFactor
+	}
+
+	allocation := cpuUsed * 100 / pricingCfg.ClusterCPU
+	if ramAllocation := ramUsed * 100 / pricingCfg.ClusterRAM; ramAllocation > allocation {
+		allocation = ramAllocation
+	}
+
+	threshold := pricingCfg.SurgeThreshold
+	maxFactor := pricingCfg.MaxSurgeFactor
+	if allocation <= threshold || maxFactor <= noSurgeFactor || threshold >= 100 {
+		return noSurgeFactor
+	}
+
+	factor := noSurgeFactor + (allocation-threshold)*(maxFactor-noSurgeFactor)/(100-threshold)
+	if factor > maxFactor {
+		factor = maxFactor
+	}
+
+	return factor
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"sync"
+	"time"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+)
+
+const (
+	claimInterval = time.Minute
+	// claimRecoveryDelay is how long a claim may stay pending before it is taken as interrupted.
+	claimRecoveryDelay = 5 * time.Minute
+	// claimTimeout is how long a claim waits for a faucet wallet to send it, well within
+	// claimRecoveryDelay so that a claim still waiting is never taken as interrupted.
+	claimTimeout = time.Minute
+)
+
+var (
+	liveLock sync.Mutex
+	// liveClaims are the ids of the pending claims a request is sending.
+	liveClaims = make(map[int64]struct{})
+)
+
+func holdClaim(id int64) {
+	liveLock.Lock()
+	defer liveLock.Unlock()
+
+	liveClaims[id] = struct{}{}
+}
+
+func releaseClaim(id int64) {
+	liveLock.Lock()
+	defer liveLock.Unlock()
+
+	delete(liveClaims, id)
+}
+
+func isLive(id int64) bool {
+	liveLock.Lock()
+	defer liveLock.Unlock()
+
+	_, ok := liveClaims[id]
+	return ok
+}
+
+func startClaimTimer() {
+	ticker := time.NewTicker(claimInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		recoverClaims()
+	}
+}
+
+// recoverClaims settles the claims whose payout was interrupted, unless a request is still
+// sending them. The faucet tx is linked to its claim before it is broadcast, so a pending
+// claim without a tx was never paid and is rolled back, as is one whose tx failed. The
+// others are finished. A sent claim whose tx failed on chain is rolled back too, so the
+// account can claim again.
+func recoverClaims() {
+	pending, err := dao.LoadFaucetClaimsByStatus(core.FaucetClaimPending, time.Now().Add(-claimRecoveryDelay))
+	if err != nil {
+		log.Errorf("LoadFaucetClaimsByStatus err:%s", err.Error())
+		return
+	}
+
+	for _, claim := range pending {
+		if isLive(claim.ID) {
+			continue
+		}
+
+		unsent, err := claimUnsent(claim)
+		if err != nil {
+			log.Errorf("claimUnsent %d err:%s", claim.ID, err.Error())
+			continue
+		}
+
+		if unsent {
+			log.Warnf("faucet claim %d of %s was interrupted, rolling it back", claim.ID, claim.Account)
+			rollbackClaim(claim)
+			continue
+		}
+
+		err = dao.FinishFaucetClaim(claim.ID, claim.TxHash)
+		if err != nil {
+			log.Errorf("FinishFaucetClaim %d err:%s", claim.ID, err.Error())
+		}
+	}
+
+	failed, err := dao.LoadFailedSentFaucetClaims()
+	if err != nil {
+		log.Errorf("LoadFailedSentFaucetClaims err:%s", err.Error())
+		return
+	}
+
+	for _, claim := range failed {
+		log.Warnf("faucet tx %s of claim %d failed, rolling it back", claim.TxHash, claim.ID)
+		rollbackClaim(claim)
+	}
+}
+
+// claimUnsent reports whether the faucet tx of the pending claim never reached the chain:
+// no tx was linked to it, or its tx failed.
+func claimUnsent(claim *core.FaucetClaim) (bool, error) {
+	if claim.TxHash == "" {
+		return true, nil
+	}
+
+	tx, err := dao.GetChainTx(claim.TxHash)
+	if err != nil {
+		return false, err
+	}
+
+	return tx.Status == core.TxStatusFailed, nil
+}
+
+func rollbackClaim(claim *core.FaucetClaim) {
+	err := dao.RollbackFaucetClaim(claim)
+	if err != nil {
+		log.Errorf("RollbackFaucetClaim %d err:%s", claim.ID, err.Error())
+	}
+}
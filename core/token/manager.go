@@ -1,85 +1,180 @@
 package token
 
 import (
-	"database/sql"
+	"context"
 	"strconv"
 	"time"
 
 	"titan-container-platform/chain"
+	"titan-container-platform/config"
+	"titan-container-platform/core"
 	"titan-container-platform/core/dao"
 	"titan-container-platform/errors"
+
+	logging "github.com/ipfs/go-log/v2"
 )
 
-const (
-	hourlyQuota     = 10000
-	maxUserQuota    = 400
-	quotaResetHours = 1
+var log = logging.Logger("token")
+
+var (
+	chainClient chain.Client
+	// faucetEnabled is set on test networks only.
+	faucetEnabled bool
+	policy        *Policy
 )
 
-func getCurrentHour() time.Time {
-	now := time.Now()
-	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+// Init initializes the token manager with the chain client and the faucet policy of
+// the network. The faucet only runs on test networks.
+func Init(client chain.Client, cfg *config.ChainAPIConfig) {
+	chainClient = client
+	faucetEnabled = cfg.Testnet
+	policy = NewPolicy(cfg)
+
+	if faucetEnabled {
+		go startClaimTimer()
+	}
 }
 
-// ClaimTokens allows the specified account to claim tokens and returns the number of tokens claimed.
-func ClaimTokens(account string) (int, error) {
-	currentHour := getCurrentHour()
+// FaucetStatusOf returns the remaining faucet budget and when the account may claim next.
+func FaucetStatusOf(account string) (*FaucetStatus, error) {
+	if !faucetEnabled {
+		return &FaucetStatus{Reason: errors.FaucetDisabled}, nil
+	}
+
+	now := time.Now()
 
-	distributedAmount, err := dao.GetOrCreateHourlyQuota(currentHour)
+	windowClaimed, err := dao.GetWindowClaimed(policy.windowStart(now))
 	if err != nil {
-		return errors.InternalServer, err
+		return nil, err
 	}
 
-	if distributedAmount >= hourlyQuota {
-		return errors.QuotaIssued, nil
+	claimed, lastClaim, err := dao.GetAccountClaims(account)
+	if err != nil {
+		return nil, err
 	}
 
-	lastClaim, err := dao.GetLastClaimByAccount(account)
-	if err != nil && err != sql.ErrNoRows {
-		return errors.InternalServer, err
-	}
+	return policy.status(now, windowClaimed, claimed, lastClaim), nil
+}
 
-	if !lastClaim.IsZero() {
-		if isToday(lastClaim) {
-			return errors.Received, nil
-		}
+// ClaimTokens sends the claim amount of the policy to the account when the policy allows it,
+// and returns the error code of why it does not. The quota is reserved with a pending claim
+// before the tokens are sent, and given back when sending fails before the faucet tx reached
+// the chain. A claim whose tx may still be included is left to the claim timer.
+func ClaimTokens(account string) (int, error) {
+	if !faucetEnabled {
+		return errors.FaucetDisabled, nil
 	}
 
-	if distributedAmount+maxUserQuota > hourlyQuota {
-		return errors.QuotaIssued, nil
+	// DATETIME keeps whole seconds, the claim time is compared when it is rolled back
+	now := time.Now().Truncate(time.Second)
+	claim := &core.FaucetClaim{
+		Account:     account,
+		Amount:      policy.Amount,
+		WindowStart: policy.windowStart(now),
+		ClaimedAt:   now,
 	}
 
-	if err == sql.ErrNoRows {
-		err = dao.SetAccountClaims(account, maxUserQuota)
-	} else {
-		err = dao.UpdateAccountClaims(account, maxUserQuota)
-	}
+	code, err := dao.ReserveFaucetClaim(claim, func(windowClaimed, claimed int64, lastClaim time.Time) int {
+		return policy.status(now, windowClaimed, claimed, lastClaim).Reason
+	})
 	if err != nil {
 		return errors.InternalServer, err
 	}
+	if code != 0 {
+		return code, nil
+	}
 
-	err = dao.UpdateHourlyQuota(maxUserQuota, currentHour)
+	// the claim timer leaves the claim alone while this request holds it
+	holdClaim(claim.ID)
+	defer releaseClaim(claim.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), claimTimeout)
+	defer cancel()
+
+	hash, err := chainClient.ClaimTokens(ctx, claim.ID, account, strconv.FormatInt(claim.Amount, 10))
 	if err != nil {
+		settleFailedClaim(claim.ID)
+
+		if err == chain.ErrFaucetDry {
+			return errors.FaucetUnavailable, err
+		}
 		return errors.InternalServer, err
 	}
 
-	err = chain.ClaimTokens(account, strconv.Itoa(maxUserQuota))
+	// a claim left pending is finished by the claim timer
+	err = dao.FinishFaucetClaim(claim.ID, hash)
 	if err != nil {
-		return errors.InternalServer, err
+		log.Errorf("FinishFaucetClaim %d err:%s", claim.ID, err.Error())
 	}
 
 	return errors.Success, nil
 }
 
-func isToday(t time.Time) bool {
-	now := time.Now()
-	year, month, day := now.Date()
-	today := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
-	tomorrow := today.AddDate(0, 0, 1)
-	return t.After(today) && t.Before(tomorrow)
+// settleFailedClaim rolls back the claim that failed to be sent, unless its faucet tx may
+// still be included.
+func settleFailedClaim(id int64) {
+	claim, err := dao.GetFaucetClaim(id)
+	if err != nil {
+		log.Errorf("GetFaucetClaim %d err:%s", id, err.Error())
+		return
+	}
+
+	unsent, err := claimUnsent(claim)
+	if err != nil {
+		log.Errorf("claimUnsent %d err:%s", id, err.Error())
+		return
+	}
+
+	if !unsent {
+		log.Warnf("faucet tx %s of claim %d may still be included, leaving it to the claim timer", claim.TxHash, id)
+		return
+	}
+
+	rollbackClaim(claim)
 }
 
 // GetBalance retrieves the balance for a given account.
 func GetBalance(account string) (string, error) {
-	return chain.GetBalance(account)
+	return chainClient.GetBalance(account)
+}
+
+// FaucetBalances retrieves the balances of the faucet wallets.
+func FaucetBalances() ([]*chain.FaucetBalance, error) {
+	return chainClient.FaucetBalances()
+}
+
+// GetNativeBalance retrieves the uttnt balance for a given account.
+func GetNativeBalance(account string) (string, error) {
+	return chainClient.GetNativeBalance(account)
+}
+
+// LoadHistory retrieves a page of the faucet, payment, renewal and refund txs of an account.
+// The txs that are still pending are looked up on chain, so they show their latest status.
+func LoadHistory(ctx context.Context, account string, page, size int) ([]*core.ChainTx, int64, error) {
+	list, total, err := dao.LoadAccountChainTxs(ctx, account, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, info := range list {
+		if info.Status != core.TxStatusPending {
+			continue
+		}
+
+		res, err := chainClient.GetTx(info.Hash)
+		if err != nil {
+			continue
+		}
+
+		info.Status = core.TxStatusConfirmed
+		if res.Code != 0 {
+			info.Status = core.TxStatusFailed
+		}
+		info.Code = res.Code
+		info.GasUsed = res.GasUsed
+		info.Height = res.Height
+		info.Log = res.Log
+	}
+
+	return list, total, nil
 }
@@ -0,0 +1,213 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"titan-container-platform/chain"
+	"titan-container-platform/config"
+	"titan-container-platform/core"
+	"titan-container-platform/core/dao"
+	"titan-container-platform/errors"
+
+	"github.com/google/uuid"
+)
+
+// The claims run against the MySQL database of TEST_DATABASE_URL, a DSN like the DatabaseURL
+// of the config with parseTime=true, and are skipped without it. Each run is on a network of
+// its own, so it has the budget windows to itself, and each test claims for an account of its own.
+
+var dbOnce sync.Once
+
+// newFaucet sets up the faucet on a fake chain and returns a new account to claim for.
+func newFaucet(t *testing.T) (*chain.FakeClient, string) {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	var err error
+	dbOnce.Do(func() {
+		cfg := &config.Config{DatabaseURL: url}
+		cfg.ChainAPI.Network = "test-" + uuid.NewString()[:8]
+		err = dao.Init(cfg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := chain.NewFakeClient(dao.ChainStore{})
+	chainClient = f
+	faucetEnabled = true
+	policy = NewPolicy(&config.ChainAPIConfig{})
+
+	return f, "titan1" + uuid.NewString()
+}
+
+func assertClaimed(t *testing.T, f *chain.FakeClient, account string, want int64) {
+	t.Helper()
+
+	status, err := FaucetStatusOf(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Claimed != want {
+		t.Errorf("claimed %d, want %d", status.Claimed, want)
+	}
+
+	balance, err := f.GetBalance(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != fmt.Sprint(want) {
+		t.Errorf("token balance %s, want %d", balance, want)
+	}
+}
+
+// sentClaim returns the sent claim of the account.
+func sentClaim(t *testing.T, account string) *core.FaucetClaim {
+	t.Helper()
+
+	// a day ahead, whatever the time zone of the database
+	list, err := dao.LoadFaucetClaimsByStatus(core.FaucetClaimSent, time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, claim := range list {
+		if claim.Account == account {
+			return claim
+		}
+	}
+
+	t.Fatalf("no sent claim of %s", account)
+	return nil
+}
+
+func TestClaimTokensFlow(t *testing.T) {
+	f, account := newFaucet(t)
+
+	code, err := ClaimTokens(account)
+	if err != nil || code != errors.Success {
+		t.Fatalf("ClaimTokens code %d err %v", code, err)
+	}
+	assertClaimed(t, f, account, policy.Amount)
+
+	claim := sentClaim(t, account)
+	tx, err := dao.GetChainTx(claim.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.ClaimID != claim.ID {
+		t.Errorf("faucet tx %s pays claim %d, want %d", tx.Hash, tx.ClaimID, claim.ID)
+	}
+
+	status, err := FaucetStatusOf(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Eligible || status.NextEligible.IsZero() {
+		t.Errorf("account eligible again right after its claim: %+v", status)
+	}
+
+	// the cooldown holds the second claim back
+	code, _ = ClaimTokens(account)
+	if code == errors.Success {
+		t.Fatal("a second claim within the cooldown went through")
+	}
+	assertClaimed(t, f, account, policy.Amount)
+}
+
+func TestClaimTokensRollback(t *testing.T) {
+	f, account := newFaucet(t)
+
+	f.SetError(fmt.Errorf("node down"))
+	code, err := ClaimTokens(account)
+	if err == nil || code != errors.InternalServer {
+		t.Fatalf("ClaimTokens code %d err %v, want %d", code, err, errors.InternalServer)
+	}
+
+	// the quota is given back, so the account claims right away once the chain is up
+	f.SetError(nil)
+	assertClaimed(t, f, account, 0)
+
+	code, err = ClaimTokens(account)
+	if err != nil || code != errors.Success {
+		t.Fatalf("ClaimTokens code %d err %v", code, err)
+	}
+	assertClaimed(t, f, account, policy.Amount)
+}
+
+func TestClaimTokensFaucetDry(t *testing.T) {
+	f, account := newFaucet(t)
+
+	f.SetError(chain.ErrFaucetDry)
+	code, _ := ClaimTokens(account)
+	if code != errors.FaucetUnavailable {
+		t.Errorf("ClaimTokens code %d, want %d", code, errors.FaucetUnavailable)
+	}
+
+	f.SetError(nil)
+	assertClaimed(t, f, account, 0)
+}
+
+// TestFaucetTxLinkedToClaim checks that the faucet tx is linked to the pending claim it pays
+// when it is recorded, and not to another claim of the same account.
+func TestFaucetTxLinkedToClaim(t *testing.T) {
+	f, account := newFaucet(t)
+
+	reserve := func() *core.FaucetClaim {
+		now := time.Now().Truncate(time.Second)
+		claim := &core.FaucetClaim{Account: account, Amount: 1, WindowStart: policy.windowStart(now), ClaimedAt: now}
+		code, err := dao.ReserveFaucetClaim(claim, func(int64, int64, time.Time) int { return 0 })
+		if err != nil || code != 0 {
+			t.Fatalf("ReserveFaucetClaim code %d err %v", code, err)
+		}
+		return claim
+	}
+
+	paid := reserve()
+	hash, err := f.ClaimTokens(context.Background(), paid.ID, account, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unpaid := reserve()
+
+	claim, err := dao.GetFaucetClaim(paid.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.TxHash != hash {
+		t.Errorf("faucet tx of the paid claim %q, want %q", claim.TxHash, hash)
+	}
+
+	claim, err = dao.GetFaucetClaim(unpaid.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.TxHash != "" {
+		t.Errorf("the unpaid claim is linked to the faucet tx %s", claim.TxHash)
+	}
+	if unsent, err := claimUnsent(claim); err != nil || !unsent {
+		t.Errorf("claimUnsent of the unpaid claim %v err %v, want true", unsent, err)
+	}
+}
+
+func TestLiveClaims(t *testing.T) {
+	holdClaim(42)
+	if !isLive(42) {
+		t.Error("a held claim is not live")
+	}
+
+	releaseClaim(42)
+	if isLive(42) {
+		t.Error("a released claim is still live")
+	}
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"time"
+
+	"titan-container-platform/config"
+	"titan-container-platform/errors"
+)
+
+const (
+	defaultFaucetBudget   = 10000
+	defaultFaucetWindow   = time.Hour
+	defaultFaucetAmount   = 400
+	defaultFaucetCooldown = 24 * time.Hour
+)
+
+// Policy limits the faucet claims: all claims share a budget per window, and an
+// account claims a fixed amount, waits a cooldown between claims and may have a
+// lifetime cap. The windows are aligned to UTC.
+type Policy struct {
+	Budget      int64
+	Window      time.Duration
+	Amount      int64
+	Cooldown    time.Duration
+	LifetimeCap int64 // no cap when zero
+}
+
+// NewPolicy creates the faucet policy of the configuration, with defaults for the zero fields.
+func NewPolicy(cfg *config.ChainAPIConfig) *Policy {
+	p := &Policy{
+		Budget:      cfg.FaucetBudget,
+		Window:      cfg.FaucetWindow,
+		Amount:      cfg.FaucetAmount,
+		Cooldown:    cfg.FaucetCooldown,
+		LifetimeCap: cfg.FaucetLifetimeCap,
+	}
+
+	if p.Budget <= 0 {
+		p.Budget = defaultFaucetBudget
+	}
+	if p.Window <= 0 {
+		p.Window = defaultFaucetWindow
+	}
+	if p.Amount <= 0 {
+		p.Amount = defaultFaucetAmount
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = defaultFaucetCooldown
+	}
+
+	return p
+}
+
+// windowStart returns the start of the UTC window holding the time.
+func (p *Policy) windowStart(t time.Time) time.Time {
+	return t.UTC().Truncate(p.Window)
+}
+
+// FaucetStatus represents what an account may claim from the faucet.
+type FaucetStatus struct {
+	Enabled         bool      `json:"enabled"`
+	Amount          int64     `json:"amount"`           // tokens per claim
+	Budget          int64     `json:"budget"`           // tokens of the current window
+	BudgetRemaining int64     `json:"budget_remaining"` // tokens left in the current window
+	WindowEnd       time.Time `json:"window_end"`
+	Claimed         int64     `json:"claimed"`                 // tokens the account claimed in total
+	LifetimeCap     int64     `json:"lifetime_cap,omitempty"`  // no cap when zero
+	Eligible        bool      `json:"eligible"`                // the account may claim now
+	NextEligible    time.Time `json:"next_eligible,omitempty"` // zero when it may claim now or never again
+	Reason          int       `json:"reason,omitempty"`        // error code of why it may not claim now
+}
+
+// status applies the policy to the amount given out in the current window and to the
+// claims of an account at the time.
+func (p *Policy) status(now time.Time, windowClaimed, claimed int64, lastClaim time.Time) *FaucetStatus {
+	windowEnd := p.windowStart(now).Add(p.Window)
+
+	s := &FaucetStatus{
+		Enabled:         true,
+		Amount:          p.Amount,
+		Budget:          p.Budget,
+		BudgetRemaining: max(p.Budget-windowClaimed, 0),
+		WindowEnd:       windowEnd,
+		Claimed:         claimed,
+		LifetimeCap:     p.LifetimeCap,
+	}
+
+	switch {
+	case p.LifetimeCap > 0 && claimed+p.Amount > p.LifetimeCap:
+		s.Reason = errors.Received
+	case !lastClaim.IsZero() && now.Before(lastClaim.Add(p.Cooldown)):
+		s.Reason = errors.Received
+		s.NextEligible = lastClaim.Add(p.Cooldown).UTC()
+	case s.BudgetRemaining < p.Amount:
+		s.Reason = errors.QuotaIssued
+		s.NextEligible = windowEnd
+	default:
+		s.Eligible = true
+	}
+
+	return s
+}
@@ -1,6 +1,12 @@
 package core
 
-import "time"
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 // User represents a user in the system.
 type User struct {
@@ -25,6 +31,56 @@ type OrderReq struct {
 	RAMSize     int `json:"ram"`      // in GB
 	StorageSize int `json:"storage"`  // in GB
 	Duration    int `json:"duration"` // Hour
+
+	Dimensions Dimensions `json:"dimensions"` // extra billable dimensions, such as pods
+
+	Plan    string `json:"plan"`    // commitment plan, empty for a fixed duration
+	Periods int    `json:"periods"` // number of committed plan periods
+
+	Mode OrderMode `json:"mode"` // prepaid or metered
+}
+
+// Dimensions maps billable dimension names to their ordered amounts.
+type Dimensions map[string]int
+
+// Value implements driver.Valuer by storing the dimensions as JSON.
+func (d Dimensions) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner by decoding the dimensions from JSON.
+func (d *Dimensions) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*d = Dimensions{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported dimensions type %T", src)
+	}
+
+	out := Dimensions{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &out); err != nil {
+			return err
+		}
+	}
+
+	*d = out
+	return nil
 }
 
 // Order represents a customer's order in the system.
@@ -35,7 +91,19 @@ type Order struct {
 	RAMSize     int         `db:"ram" json:"ram"`
 	StorageSize int         `db:"storage" json:"storage"`
 	Duration    int         `db:"duration" json:"duration"` // Hour
+	Blocks      uint64      `db:"blocks" json:"blocks"`     // duration on the order contract, fixed when the payment msg is first built
 	Price       int         `db:"price" json:"price"`
+	SurgeFactor int         `db:"surge_factor" json:"surge_factor"` // in percent, locked at creation
+	Dimensions  Dimensions  `db:"dimensions" json:"dimensions"`
+	Plan        string      `db:"plan" json:"plan"`
+	Periods     int         `db:"periods" json:"periods"`
+	PaidPeriods int         `db:"paid_periods" json:"paid_periods"`
+	PeriodEnd   time.Time   `db:"period_end" json:"period_end"`
+	Mode        OrderMode   `db:"mode" json:"mode"`
+	Network     string      `db:"network" json:"network"` // network the order is paid on
+	TxHash      string      `db:"tx_hash" json:"tx_hash"` // user-signed payment tx
+	RefundHash  string      `db:"refund_hash" json:"refund_hash"`
+	RenewHash   string      `db:"renew_hash" json:"renew_hash"` // renewal tx of the next plan period, until it is confirmed
 	Status      OrderStatus `db:"status" json:"status"`
 	CreatedAt   time.Time   `db:"created_at" json:"created_at"`
 }
@@ -56,4 +124,160 @@ const (
 	OrderStatusFailed
 	// OrderStatusTimeout indicates that the order has payment timeout.
 	OrderStatusTimeout
+	// OrderStatusSuspended indicates that the metered order has run out of balance.
+	OrderStatusSuspended
+	// OrderStatusRefunded indicates that the locked funds of a failed or timed out order were refunded.
+	OrderStatusRefunded
+	// OrderStatusClosed indicates that the metered order was closed by its owner.
+	OrderStatusClosed
+)
+
+// OrderMode represents how an order is billed.
+type OrderMode int
+
+const (
+	// OrderModePrepaid indicates that the order is paid up front for a fixed duration.
+	OrderModePrepaid OrderMode = iota
+	// OrderModeMetered indicates that the order is billed hourly by usage from the prepaid balance.
+	OrderModeMetered
+	// OrderModeDeposit indicates that the order tops up the prepaid balance.
+	OrderModeDeposit
+)
+
+// UsageRecord represents the sampled resource usage of a metered order for an hour.
+type UsageRecord struct {
+	OrderID   string    `db:"order_id" json:"order_id"`
+	Account   string    `db:"account" json:"account"`
+	Hour      time.Time `db:"hour" json:"hour"`
+	CPU       float64   `db:"cpu" json:"cpu"`         // cores
+	RAM       float64   `db:"ram" json:"ram"`         // in GB
+	Storage   float64   `db:"storage" json:"storage"` // in GB
+	Cost      int       `db:"cost" json:"cost"`
+	Network   string    `db:"network" json:"network"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// TxPurpose represents why the platform broadcast a tx.
+type TxPurpose string
+
+const (
+	// TxPurposeFaucet is a faucet transfer to a user.
+	TxPurposeFaucet TxPurpose = "faucet"
+	// TxPurposeOrderPayment is a user-signed order payment.
+	TxPurposeOrderPayment TxPurpose = "order_payment"
+	// TxPurposeOrderRenewal is the payment of the next period of a commitment plan order.
+	TxPurposeOrderRenewal TxPurpose = "order_renewal"
+	// TxPurposeOrderCancel closes an order on the order contract and refunds its funds.
+	TxPurposeOrderCancel TxPurpose = "order_cancel"
+	// TxPurposeOrderSettle releases the funds of the elapsed part of an order to the provider.
+	TxPurposeOrderSettle TxPurpose = "order_settle"
+	// TxPurposeWithdraw transfers settled funds out of the order contract.
+	TxPurposeWithdraw TxPurpose = "withdraw"
 )
+
+// TxStatus represents the status of a broadcast tx.
+type TxStatus int
+
+const (
+	// TxStatusPending indicates that the tx is broadcast but not yet included in a block.
+	TxStatusPending TxStatus = iota
+	// TxStatusConfirmed indicates that the tx is included in a block and succeeded.
+	TxStatusConfirmed
+	// TxStatusFailed indicates that the tx was rejected, failed in a block, or was never included.
+	TxStatusFailed
+)
+
+// ChainTx represents a tx the platform broadcast to the chain.
+type ChainTx struct {
+	Hash      string    `db:"hash" json:"hash"`
+	Purpose   TxPurpose `db:"purpose" json:"purpose"`
+	Account   string    `db:"account" json:"account"`
+	OrderID   string    `db:"order_id" json:"order_id"`
+	Status    TxStatus  `db:"status" json:"status"`
+	Code      uint32    `db:"code" json:"code"`
+	GasUsed   int64     `db:"gas_used" json:"gas_used"`
+	Height    int64     `db:"height" json:"height"`
+	Log       string    `db:"log" json:"log"`
+	Network   string    `db:"network" json:"network"`
+	ClaimID   int64     `db:"claim_id" json:"-"` // faucet claim a faucet tx pays, one row per recipient
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// DepositPayment represents a token transfer to the deposit address that pays the order in its memo.
+type DepositPayment struct {
+	ID        int64     `db:"id" json:"id"`
+	TxHash    string    `db:"tx_hash" json:"tx_hash"`
+	Idx       int       `db:"idx" json:"idx"` // position of the transfer in its tx
+	OrderID   string    `db:"order_id" json:"order_id"`
+	Sender    string    `db:"sender" json:"sender"`
+	Amount    int64     `db:"amount" json:"amount"`
+	Height    int64     `db:"height" json:"height"`
+	Credited  string    `db:"credited" json:"credited"` // account the amount was credited to when it matched no payable order
+	Network   string    `db:"network" json:"network"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Settlement represents a claim of the earned funds of an order from the order contract.
+type Settlement struct {
+	ID        int64     `db:"id" json:"id"`
+	OrderID   string    `db:"order_id" json:"order_id"`
+	Account   string    `db:"account" json:"account"`
+	Amount    int64     `db:"amount" json:"amount"`
+	Height    int64     `db:"height" json:"height"`
+	TxHash    string    `db:"tx_hash" json:"tx_hash"`
+	Network   string    `db:"network" json:"network"`
+	Confirmed bool      `db:"confirmed" json:"confirmed"` // the amount is the one released by the confirmed tx, an estimate before
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ChainOrder mirrors an order of the order contract.
+type ChainOrder struct {
+	ID          string    `db:"id" json:"id"`
+	Initiator   string    `db:"initiator" json:"initiator"`
+	CPU         uint32    `db:"cpu" json:"cpu"`
+	Memory      uint32    `db:"memory" json:"memory"`
+	Disk        uint32    `db:"disk" json:"disk"`
+	Duration    uint64    `db:"duration" json:"duration"` // in blocks
+	LockedFunds uint64    `db:"locked_funds" json:"locked_funds"`
+	StartHeight uint64    `db:"start_height" json:"start_height"`
+	EndHeight   uint64    `db:"end_height" json:"end_height"` // start height plus duration
+	Status      string    `db:"status" json:"status"`
+	Network     string    `db:"network" json:"network"`
+	SyncedAt    time.Time `db:"synced_at" json:"synced_at"`
+}
+
+// ChainOrderFilter selects mirrored orders, the zero fields match every order.
+type ChainOrderFilter struct {
+	Initiator  string
+	Status     string
+	FromHeight uint64 // orders ending at or after the height
+	ToHeight   uint64 // orders starting at or before the height
+}
+
+// FaucetClaimStatus represents the status of a faucet claim.
+type FaucetClaimStatus int
+
+const (
+	// FaucetClaimPending indicates that the claim reserved its quota and is being sent.
+	FaucetClaimPending FaucetClaimStatus = iota
+	// FaucetClaimSent indicates that the tx of the claim was broadcast.
+	FaucetClaimSent
+	// FaucetClaimFailed indicates that the claim was not paid out and its quota was given back.
+	FaucetClaimFailed
+)
+
+// FaucetClaim represents a claim of faucet tokens by an account.
+type FaucetClaim struct {
+	ID          int64             `db:"id" json:"id"`
+	Account     string            `db:"account" json:"account"`
+	Amount      int64             `db:"amount" json:"amount"`
+	WindowStart time.Time         `db:"window_start" json:"window_start"`
+	ClaimedAt   time.Time         `db:"claimed_at" json:"claimed_at"`
+	PrevClaim   sql.NullTime      `db:"prev_claim" json:"-"` // last claim of the account before this one, restored on a rollback
+	Status      FaucetClaimStatus `db:"status" json:"status"`
+	TxHash      string            `db:"tx_hash" json:"tx_hash"`
+	Network     string            `db:"network" json:"network"`
+	CreatedAt   time.Time         `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time         `db:"updated_at" json:"updated_at"`
+}
@@ -30,6 +30,11 @@ const (
 	InvalidPublicKey
 	QuotaIssued
 	Received
+	InsufficientBalance
+	FaucetUnavailable
+	PermissionDenied
+	FaucetDisabled
+	CaptchaInvalid
 
 	Unknown = -1
 )
@@ -46,6 +51,11 @@ var ErrMap = map[int]string{
 	InvalidPublicKey:     "invalid public key: 无效的公钥地址",
 	QuotaIssued:          "the quota has been issued: 额度已发完",
 	Received:             "received: 已领取",
+	InsufficientBalance:  "insufficient balance: 余额不足",
+	FaucetUnavailable:    "faucet unavailable: 水龙头余额不足, 请稍后再试",
+	PermissionDenied:     "permission denied: 没有权限",
+	FaucetDisabled:       "faucet is only available on test networks: 水龙头仅在测试网开放",
+	CaptchaInvalid:       "captcha verification failed: 验证码校验失败, 请重新验证",
 }
 
 // ErrUnknown represents an unknown error.
@@ -37,7 +37,8 @@ func CreateUserAccount(userName string) error {
 }
 
 // CreateSpaceAndResourceQuotas creates a space and resource quotas for a user.
-func CreateSpaceAndResourceQuotas(order, userName string, cpu, ram, storage int) error {
+// The hard map holds the resource quota limits keyed by KubeSphere quota key.
+func CreateSpaceAndResourceQuotas(order, userName string, hard map[string]string) error {
 	err := createUserSpace(order, userName)
 	if err != nil {
 		log.Errorf("CreateUserSpace: %s", err.Error())
@@ -51,7 +52,7 @@ func CreateSpaceAndResourceQuotas(order, userName string, cpu, ram, storage int)
 		return err
 	}
 
-	err = createUserResourceQuotas(order, cpu, ram, storage)
+	err = createUserResourceQuotas(order, hard)
 	if err != nil {
 		log.Errorf("CreateUserResourceQuotas: %s", err.Error())
 	}
@@ -122,9 +123,51 @@ func changeWorkspaceMembers(order, userName string) error {
 }
 
 // createUserResourceQuotas creates resource quotas for a user.
-// It takes an order string and resource limits for CPU, ram, and storage.
-func createUserResourceQuotas(order string, cpu, ram, storage int) error {
-	body := map[string]interface{}{
+// It takes an order string and the hard resource limits rendered from the order dimensions.
+func createUserResourceQuotas(order string, hard map[string]string) error {
+	body := resourceQuotaBody(order, hard)
+
+	path := fmt.Sprintf("/clusters/%s/kapis/tenant.kubesphere.io/v1beta1/workspaces/%s/resourcequotas", cluster, order)
+	_, err := doRequest("POST", path, body)
+	if err != nil {
+		log.Errorf("CreateUserResourceQuotas err:%s", err.Error())
+		return err
+	}
+
+	// log.Infoln("CreateUserResourceQuotas rsp-----")
+	// log.Infoln(string(rsp))
+
+	return nil
+}
+
+// UpdateResourceQuotas replaces the hard resource limits of the order workspace.
+func UpdateResourceQuotas(order string, hard map[string]string) error {
+	body := resourceQuotaBody(order, hard)
+
+	path := fmt.Sprintf("/clusters/%s/kapis/tenant.kubesphere.io/v1beta1/workspaces/%s/resourcequotas/%s", cluster, order, order)
+	_, err := doRequest("PUT", path, body)
+	if err != nil {
+		log.Errorf("UpdateResourceQuotas err:%s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// DeleteResourceQuotas removes the resource quota of the order workspace.
+func DeleteResourceQuotas(order string) error {
+	path := fmt.Sprintf("/clusters/%s/kapis/tenant.kubesphere.io/v1beta1/workspaces/%s/resourcequotas/%s", cluster, order, order)
+	_, err := doRequest("DELETE", path, nil)
+	if err != nil {
+		log.Errorf("DeleteResourceQuotas err:%s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func resourceQuotaBody(order string, hard map[string]string) map[string]interface{} {
+	return map[string]interface{}{
 		"metadata": map[string]interface{}{
 			"labels": map[string]interface{}{
 				"kubesphere.io/workspace": order,
@@ -135,27 +178,8 @@ func createUserResourceQuotas(order string, cpu, ram, storage int) error {
 				"kubesphere.io/workspace": order,
 			},
 			"quota": map[string]interface{}{
-				"hard": map[string]interface{}{
-					"limits.cpu":             fmt.Sprintf("%d", cpu),
-					"limits.memory":          fmt.Sprintf("%dGi", ram),
-					"requests.cpu":           fmt.Sprintf("%d", cpu),
-					"requests.memory":        fmt.Sprintf("%dGi", ram),
-					"requests.storage":       fmt.Sprintf("%dGi", storage),
-					"persistentvolumeclaims": fmt.Sprintf("%d", storage),
-				},
+				"hard": hard,
 			},
 		},
 	}
-
-	path := fmt.Sprintf("/clusters/%s/kapis/tenant.kubesphere.io/v1beta1/workspaces/%s/resourcequotas", cluster, order)
-	_, err := doRequest("POST", path, body)
-	if err != nil {
-		log.Errorf("CreateUserResourceQuotas err:%s", err.Error())
-		return err
-	}
-
-	// log.Infoln("CreateUserResourceQuotas rsp-----")
-	// log.Infoln(string(rsp))
-
-	return nil
 }
@@ -120,6 +120,11 @@ func getToken() string {
 }
 
 func doRequest(method, path string, body interface{}) ([]byte, error) {
+	return doRequestWithType(method, path, "application/json", body)
+}
+
+// doRequestWithType sends the body with the content type, such as a merge patch.
+func doRequestWithType(method, path, contentType string, body interface{}) ([]byte, error) {
 	url := fmt.Sprintf("%s%s", serverURL, path)
 
 	var req *http.Request
@@ -140,7 +145,7 @@ func doRequest(method, path string, body interface{}) ([]byte, error) {
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	// req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	client := &http.Client{}
@@ -0,0 +1,144 @@
+package kubesphere
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	netURL "net/url"
+)
+
+const (
+	metricCPUUsage    = "workspace_cpu_usage"
+	metricMemoryUsage = "workspace_memory_usage_wo_cache"
+
+	bytesPerGB = 1 << 30
+)
+
+// WorkspaceUsage holds the resources a workspace is currently using.
+type WorkspaceUsage struct {
+	CPU     float64 // cores
+	RAM     float64 // in GB
+	Storage float64 // requested by persistent volume claims, in GB
+}
+
+// metricSample is a monitoring sample whose value is a [timestamp, "value"] pair.
+type metricSample struct {
+	Value []interface{} `json:"value"`
+}
+
+type monitoringResponse struct {
+	Results []struct {
+		MetricName string `json:"metric_name"`
+		Data       struct {
+			Result []metricSample `json:"result"`
+		} `json:"data"`
+	} `json:"results"`
+}
+
+type resourceQuotaResponse struct {
+	Status struct {
+		Total struct {
+			Used map[string]string `json:"used"`
+		} `json:"total"`
+	} `json:"status"`
+}
+
+// GetWorkspaceUsage samples the cpu and memory usage of the workspace from KubeSphere monitoring,
+// and the requested storage from its resource quota.
+func GetWorkspaceUsage(workspace string) (*WorkspaceUsage, error) {
+	filter := netURL.QueryEscape(fmt.Sprintf("%s|%s$", metricCPUUsage, metricMemoryUsage))
+	path := fmt.Sprintf("/clusters/%s/kapis/monitoring.kubesphere.io/v1alpha3/workspaces/%s?metrics_filter=%s", cluster, workspace, filter)
+	rsp, err := doRequest("GET", path, nil)
+	if err != nil {
+		log.Errorf("GetWorkspaceUsage err:%s", err.Error())
+		return nil, err
+	}
+
+	var metrics monitoringResponse
+	err = json.Unmarshal(rsp, &metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &WorkspaceUsage{}
+	for _, result := range metrics.Results {
+		value := sumMetricValues(result.Data.Result)
+		switch result.MetricName {
+		case metricCPUUsage:
+			usage.CPU = value
+		case metricMemoryUsage:
+			usage.RAM = value / bytesPerGB
+		}
+	}
+
+	path = fmt.Sprintf("/clusters/%s/kapis/tenant.kubesphere.io/v1beta1/workspaces/%s/resourcequotas/%s", cluster, workspace, workspace)
+	rsp, err = doRequest("GET", path, nil)
+	if err != nil {
+		log.Errorf("GetWorkspaceUsage quota err:%s", err.Error())
+		return nil, err
+	}
+
+	var quota resourceQuotaResponse
+	err = json.Unmarshal(rsp, &quota)
+	if err != nil {
+		return nil, err
+	}
+
+	if used, ok := quota.Status.Total.Used["requests.storage"]; ok {
+		storage, err := parseQuantity(used)
+		if err != nil {
+			return nil, err
+		}
+		usage.Storage = storage / bytesPerGB
+	}
+
+	return usage, nil
+}
+
+// sumMetricValues sums the values of the samples.
+func sumMetricValues(results []metricSample) float64 {
+	total := 0.0
+	for _, r := range results {
+		if len(r.Value) != 2 {
+			continue
+		}
+
+		s, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		total += v
+	}
+
+	return total
+}
+
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// parseQuantity parses a kubernetes resource quantity such as "20Gi" into bytes.
+func parseQuantity(q string) (float64, error) {
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(q, s.suffix) {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(q, s.suffix), 64)
+			if err != nil {
+				return 0, err
+			}
+			return v * s.multiplier, nil
+		}
+	}
+
+	return strconv.ParseFloat(q, 64)
+}
@@ -0,0 +1,179 @@
+package kubesphere
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	netURL "net/url"
+)
+
+// suspendedReplicasKey is the annotation that keeps the replicas of a workload stopped by StopWorkloads.
+const suspendedReplicasKey = "titan.io/suspended-replicas"
+
+// scalableKinds are the workload resources that are scaled to zero to stop a workspace.
+var scalableKinds = []string{"deployments", "statefulsets"}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Annotations     map[string]string `json:"annotations"`
+	OwnerReferences []struct {
+		Kind string `json:"kind"`
+	} `json:"ownerReferences"`
+}
+
+type objectList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+func listObjects(path string) (*objectList, error) {
+	rsp, err := doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var list objectList
+	err = json.Unmarshal(rsp, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// workspaceNamespaces returns the namespaces of the workspace.
+func workspaceNamespaces(workspace string) ([]string, error) {
+	selector := netURL.QueryEscape("kubesphere.io/workspace=" + workspace)
+	list, err := listObjects(fmt.Sprintf("/clusters/%s/api/v1/namespaces?labelSelector=%s", cluster, selector))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Metadata.Name)
+	}
+
+	return names, nil
+}
+
+// StopWorkloads scales the deployments and stateful sets of the workspace to zero and
+// deletes its pods that no controller owns, so nothing of it keeps running. The replicas
+// are kept in an annotation for StartWorkloads. It can be called again after a failure.
+func StopWorkloads(workspace string) error {
+	namespaces, err := workspaceNamespaces(workspace)
+	if err != nil {
+		log.Errorf("StopWorkloads err:%s", err.Error())
+		return err
+	}
+
+	for _, ns := range namespaces {
+		for _, kind := range scalableKinds {
+			path := fmt.Sprintf("/clusters/%s/apis/apps/v1/namespaces/%s/%s", cluster, ns, kind)
+			list, err := listObjects(path)
+			if err != nil {
+				log.Errorf("StopWorkloads list %s err:%s", kind, err.Error())
+				return err
+			}
+
+			for _, item := range list.Items {
+				if _, ok := item.Metadata.Annotations[suspendedReplicasKey]; ok {
+					// stopped before
+					continue
+				}
+
+				replicas := 1
+				if item.Spec.Replicas != nil {
+					replicas = *item.Spec.Replicas
+				}
+
+				patch := map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{suspendedReplicasKey: strconv.Itoa(replicas)},
+					},
+					"spec": map[string]interface{}{"replicas": 0},
+				}
+
+				_, err = doRequestWithType("PATCH", path+"/"+item.Metadata.Name, "application/merge-patch+json", patch)
+				if err != nil {
+					log.Errorf("StopWorkloads scale %s/%s err:%s", kind, item.Metadata.Name, err.Error())
+					return err
+				}
+			}
+		}
+
+		path := fmt.Sprintf("/clusters/%s/api/v1/namespaces/%s/pods", cluster, ns)
+		pods, err := listObjects(path)
+		if err != nil {
+			log.Errorf("StopWorkloads list pods err:%s", err.Error())
+			return err
+		}
+
+		for _, pod := range pods.Items {
+			if len(pod.Metadata.OwnerReferences) > 0 {
+				continue
+			}
+
+			_, err = doRequest("DELETE", path+"/"+pod.Metadata.Name, nil)
+			if err != nil {
+				log.Errorf("StopWorkloads delete pod %s err:%s", pod.Metadata.Name, err.Error())
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// StartWorkloads scales the workloads stopped by StopWorkloads back to their replicas.
+// The deleted bare pods are not recreated.
+func StartWorkloads(workspace string) error {
+	namespaces, err := workspaceNamespaces(workspace)
+	if err != nil {
+		log.Errorf("StartWorkloads err:%s", err.Error())
+		return err
+	}
+
+	for _, ns := range namespaces {
+		for _, kind := range scalableKinds {
+			path := fmt.Sprintf("/clusters/%s/apis/apps/v1/namespaces/%s/%s", cluster, ns, kind)
+			list, err := listObjects(path)
+			if err != nil {
+				log.Errorf("StartWorkloads list %s err:%s", kind, err.Error())
+				return err
+			}
+
+			for _, item := range list.Items {
+				value, ok := item.Metadata.Annotations[suspendedReplicasKey]
+				if !ok {
+					continue
+				}
+
+				replicas, err := strconv.Atoi(value)
+				if err != nil {
+					replicas = 1
+				}
+
+				patch := map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{suspendedReplicasKey: nil},
+					},
+					"spec": map[string]interface{}{"replicas": replicas},
+				}
+
+				_, err = doRequestWithType("PATCH", path+"/"+item.Metadata.Name, "application/merge-patch+json", patch)
+				if err != nil {
+					log.Errorf("StartWorkloads scale %s/%s err:%s", kind, item.Metadata.Name, err.Error())
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,15 +12,37 @@ import (
 	"titan-container-platform/config"
 	"titan-container-platform/core/dao"
 	"titan-container-platform/core/order"
+	"titan-container-platform/core/token"
 	"titan-container-platform/kubesphere"
 
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var log = logging.Logger("main")
 
+// network overrides the network profile of the config file.
+var network string
+
+var rootCmd = &cobra.Command{
+	Use:   "titan-container-platform",
+	Short: "Titan container platform server",
+	Run: func(cmd *cobra.Command, args []string) {
+		runServer()
+	},
+}
+
 func main() {
+	rootCmd.Flags().StringVar(&network, "network", "", "network profile to run on, the Network of the config file when empty")
+	rootCmd.AddCommand(pricingCmd)
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func runServer() {
 	OsSignal := make(chan os.Signal, 1)
 
 	viper.AddConfigPath(".")
@@ -33,6 +56,13 @@ func main() {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		log.Fatalf("unmarshaling config file: %v\n", err)
 	}
+
+	if network == "" {
+		network = cfg.Network
+	}
+	if err := cfg.SelectNetwork(network); err != nil {
+		log.Fatalf("selecting network: %v\n", err)
+	}
 	config.Cfg = cfg
 	if cfg.Mode == "debug" {
 		logging.SetDebugLogging()
@@ -42,14 +72,39 @@ func main() {
 		log.Fatalf("initital: %v\n", err)
 	}
 
-	go api.ServerAPI(&cfg)
+	chainClient, err := newChainClient(&cfg.ChainAPI)
+	if err != nil {
+		log.Fatalf("initital chain: %v\n", err)
+	}
 
 	kubesphere.Init(&cfg.KubesphereAPI)
-	order.Init()
-	chain.Init(&cfg.ChainAPI)
+	token.Init(chainClient, &cfg.ChainAPI)
+	if err := order.Init(&cfg.Pricing, chainClient); err != nil {
+		log.Fatalf("initital order: %v\n", err)
+	}
+
+	indexer := chain.NewIndexer(chainClient, dao.ChainStore{})
+	go indexer.Run(context.Background())
+
+	watcher := chain.NewWatcher(chainClient, dao.ChainStore{})
+	watcher.Subscribe(indexer.HandleEvent)
+	watcher.Subscribe(order.HandleChainEvent)
+	go watcher.Run(context.Background())
+	go chain.NewTracker(chainClient, dao.ChainStore{}).Run(context.Background())
+
+	go api.ServerAPI(&cfg)
 
 	signal.Notify(OsSignal, syscall.SIGINT, syscall.SIGTERM)
 	_ = <-OsSignal
 
 	fmt.Printf("Exiting received OsSignal\n")
 }
+
+func newChainClient(cfg *config.ChainAPIConfig) (chain.Client, error) {
+	if cfg.Fake {
+		log.Warn("using the in-memory fake chain")
+		return chain.NewFakeClient(dao.ChainStore{}), nil
+	}
+
+	return chain.NewClient(cfg, dao.ChainStore{})
+}
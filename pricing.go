@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"titan-container-platform/core"
+	"titan-container-platform/core/order"
+
+	"github.com/spf13/cobra"
+)
+
+// pricingGrid holds the resource combinations that are quoted.
+type pricingGrid struct {
+	cpu      []int
+	ram      []int
+	storage  []int
+	duration []int
+	plan     string
+	periods  int
+}
+
+func (g *pricingGrid) register(cmd *cobra.Command) {
+	cmd.Flags().IntSliceVar(&g.cpu, "cpu", []int{1, 2, 4, 8, 16, 32}, "cpu cores to quote")
+	cmd.Flags().IntSliceVar(&g.ram, "ram", []int{1, 2, 4, 8, 16, 32, 64}, "ram sizes in GB to quote")
+	cmd.Flags().IntSliceVar(&g.storage, "storage", []int{40, 100, 500, 2000, 4000}, "storage sizes in GB to quote")
+	cmd.Flags().IntSliceVar(&g.duration, "duration", []int{1, 24, 72, 168, 720}, "durations in hours to quote")
+	cmd.Flags().StringVar(&g.plan, "plan", "", "commitment plan to quote instead of the durations")
+	cmd.Flags().IntVar(&g.periods, "periods", 1, "number of plan periods")
+}
+
+// each calls fn with every combination of the grid.
+func (g *pricingGrid) each(fn func(req *core.OrderReq)) {
+	durations := g.duration
+	if g.plan != "" {
+		durations = []int{0}
+	}
+
+	for _, cpu := range g.cpu {
+		for _, ram := range g.ram {
+			for _, storage := range g.storage {
+				for _, duration := range durations {
+					fn(&core.OrderReq{CPUCores: cpu, RAMSize: ram, StorageSize: storage, Duration: duration, Plan: g.plan, Periods: g.periods})
+				}
+			}
+		}
+	}
+}
+
+func loadPricingModel(path string) (*order.Model, error) {
+	if path == "" {
+		return order.DefaultModel, nil
+	}
+
+	return order.LoadModel(path)
+}
+
+var pricingCmd = &cobra.Command{
+	Use:   "pricing",
+	Short: "Simulate and compare pricing models",
+}
+
+func init() {
+	var (
+		quoteGrid pricingGrid
+		modelPath string
+		gapsOnly  bool
+	)
+
+	quoteCmd := &cobra.Command{
+		Use:   "quote",
+		Short: "Print quotes for a grid of resource combinations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := loadPricingModel(modelPath)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CPU\tRAM\tSTORAGE\tDURATION\tTOTAL\tGAPS")
+
+			quoteGrid.each(func(req *core.OrderReq) {
+				quote := m.Quote(req, 100)
+				if gapsOnly && len(quote.Gaps) == 0 {
+					return
+				}
+
+				fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%s\n", req.CPUCores, req.RAMSize, req.StorageSize, quote.Duration, quote.Total, strings.Join(quote.Gaps, ","))
+			})
+
+			return w.Flush()
+		},
+	}
+	quoteGrid.register(quoteCmd)
+	quoteCmd.Flags().StringVar(&modelPath, "model", "", "pricing model file, the built-in model when empty")
+	quoteCmd.Flags().BoolVar(&gapsOnly, "gaps", false, "only print combinations that fall into zero-price gaps")
+
+	var (
+		diffGrid  pricingGrid
+		oldPath   string
+		newPath   string
+		showEqual bool
+	)
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the quotes of two pricing models",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldModel, err := loadPricingModel(oldPath)
+			if err != nil {
+				return err
+			}
+
+			newModel, err := loadPricingModel(newPath)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "CPU\tRAM\tSTORAGE\tDURATION\t%s\t%s\tCHANGE\tOLD GAPS\tNEW GAPS\n", oldModel.Version, newModel.Version)
+
+			changed := 0
+			diffGrid.each(func(req *core.OrderReq) {
+				oldQuote := oldModel.Quote(req, 100)
+				newQuote := newModel.Quote(req, 100)
+				if oldQuote.Total == newQuote.Total && len(oldQuote.Gaps)+len(newQuote.Gaps) == 0 && !showEqual {
+					return
+				}
+
+				if oldQuote.Total != newQuote.Total {
+					changed++
+				}
+
+				change := "-"
+				if oldQuote.Total > 0 {
+					change = fmt.Sprintf("%+.1f%%", float64(newQuote.Total-oldQuote.Total)*100/float64(oldQuote.Total))
+				}
+
+				fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n", req.CPUCores, req.RAMSize, req.StorageSize, newQuote.Duration,
+					oldQuote.Total, newQuote.Total, change, strings.Join(oldQuote.Gaps, ","), strings.Join(newQuote.Gaps, ","))
+			})
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Printf("%d quotes changed\n", changed)
+			return nil
+		},
+	}
+	diffGrid.register(diffCmd)
+	diffCmd.Flags().StringVar(&oldPath, "old", "", "current pricing model file, the built-in model when empty")
+	diffCmd.Flags().StringVar(&newPath, "new", "", "proposed pricing model file, the built-in model when empty")
+	diffCmd.Flags().BoolVar(&showEqual, "all", false, "also print unchanged quotes")
+
+	pricingCmd.AddCommand(quoteCmd, diffCmd)
+}